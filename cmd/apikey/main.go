@@ -0,0 +1,132 @@
+// Command apikey issues, lists and revokes API keys for machine clients. It
+// connects to the database configured like the server, by the DB_* variables
+// or the file named by CONFIG_FILE:
+//
+//	go run ./cmd/apikey create -name ingest -scopes read,write
+//	go run ./cmd/apikey list
+//	go run ./cmd/apikey revoke -id 3
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/database"
+)
+
+const usage = `Usage:
+  apikey create -name NAME [-scopes read,write]
+  apikey list
+  apikey revoke -id ID`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	db, err := database.NewConnection(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "create":
+		create(db, args)
+	case "list":
+		list(db)
+	case "revoke":
+		revoke(db, args)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func create(db *database.DB, args []string) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	name := flags.String("name", "", "author name shown on articles created with the key")
+	scopeList := flags.String("scopes", "read", "comma separated scopes: read, write, publish")
+	flags.Parse(args)
+
+	if strings.TrimSpace(*name) == "" {
+		log.Fatal("-name is required")
+	}
+
+	var scopes []string
+	for _, s := range strings.Split(*scopeList, ",") {
+		scope, ok := auth.ParseScope(s)
+		if !ok {
+			log.Fatalf("Unknown scope %q", s)
+		}
+		scopes = append(scopes, string(scope))
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	apiKey, err := db.CreateAPIKey(context.Background(), strings.TrimSpace(*name), prefix, hash, scopes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Created API key %d (%s) with scopes %s.\n", apiKey.ID, apiKey.Name, strings.Join(apiKey.Scopes, ","))
+	fmt.Println("Store it now, it cannot be shown again:")
+	fmt.Println(key)
+}
+
+func list(db *database.DB) {
+	keys, err := db.ListAPIKeys(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPREFIX\tSCOPES\tCREATED\tLAST USED\tREVOKED")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.ID, key.Name, key.Prefix, strings.Join(key.Scopes, ","),
+			key.CreatedAt.Format(time.RFC3339), formatTime(key.LastUsedAt), formatTime(key.RevokedAt))
+	}
+	w.Flush()
+}
+
+func revoke(db *database.DB, args []string) {
+	flags := flag.NewFlagSet("revoke", flag.ExitOnError)
+	id := flags.Int("id", 0, "ID of the key to revoke")
+	flags.Parse(args)
+
+	apiKey, err := db.RevokeAPIKey(context.Background(), *id)
+	if err == sql.ErrNoRows {
+		log.Fatalf("API key %d not found", *id)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Revoked API key %d (%s).\n", apiKey.ID, apiKey.Name)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,65 @@
+// Command schema prints the GraphQL schema in SDL, or checks it against a
+// committed snapshot:
+//
+//	go run ./cmd/schema > schema.graphql
+//	go run ./cmd/schema -check schema.graphql
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+)
+
+func main() {
+	check := flag.String("check", "", "compare the schema against this SDL snapshot and report changes")
+	flag.Parse()
+
+	// Building the schema does not touch the database
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	if err != nil {
+		log.Fatalf("Failed to create GraphQL schema: %v", err)
+	}
+	sdl := graph.PrintSchema(schema)
+
+	if *check == "" {
+		fmt.Print(sdl)
+		return
+	}
+
+	snapshot, err := os.ReadFile(*check)
+	if err != nil {
+		log.Fatalf("Failed to read schema snapshot: %v", err)
+	}
+
+	if string(snapshot) == sdl {
+		fmt.Printf("%s is up to date\n", *check)
+		return
+	}
+
+	changes, err := graph.DiffSchemas(string(snapshot), sdl)
+	if err != nil {
+		log.Fatalf("Failed to compare schemas: %v", err)
+	}
+
+	breaking := 0
+	fmt.Printf("%s is out of date:\n", *check)
+	for _, change := range changes {
+		fmt.Printf("  %s\n", change)
+		if change.Breaking {
+			breaking++
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Println("  formatting only")
+	}
+	if breaking > 0 {
+		fmt.Printf("\n%d breaking change(s) found.\n", breaking)
+	}
+	fmt.Printf("Run `go run ./cmd/schema > %s` to update the snapshot.\n", *check)
+	os.Exit(1)
+}
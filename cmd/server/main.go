@@ -2,88 +2,288 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/cache"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
 	"github.com/StillLearnSVN/go-graphql-articles/internal/database"
 	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/health"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/logging"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/metrics"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/middleware"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/ratelimit"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/storage"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/tlscert"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/tracing"
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 	"github.com/graphql-go/handler"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel"
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fatal("failed to load configuration", err)
+	}
+
+	// Log JSON lines; the std log package, used by dependencies, goes through it too
+	logger := logging.New(os.Stdout, cfg.Logging)
+	slog.SetDefault(logger)
+
+	// Set up tracing first, so that the database connection is instrumented
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+	}()
+
 	// Connect to database
-	db, err := database.NewConnection()
+	db, err := database.NewConnection(cfg.Database)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("failed to connect to database", err)
 	}
 	defer db.Close()
+	db.SetSlowQueryThreshold(cfg.Logging.SlowQueryThreshold)
 
 	// Run migrations
 	if err := db.RunMigrations(); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		fatal("failed to run migrations", err)
 	}
 
 	// Create GraphQL resolver and schema
-	resolver := graph.NewResolver(db)
+	resolver := graph.NewResolver(db, cfg.GraphQL)
 	schema, err := graph.CreateSchema(resolver)
 	if err != nil {
-		log.Fatalf("Failed to create GraphQL schema: %v", err)
+		fatal("failed to create GraphQL schema", err)
+	}
+	schema.AddExtensions(logging.GraphQLExtension(cfg.Logging.SlowOperationThreshold))
+
+	// Store uploaded article images on the local disk
+	images, err := storage.NewDiskStore(cfg.Uploads.Dir, cfg.Uploads.BaseURL)
+	if err != nil {
+		fatal("failed to open image storage", err)
+	}
+	resolver.SetImageStore(images)
+
+	// Collect Prometheus metrics of requests, resolvers and the connection pool
+	var serverMetrics *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		serverMetrics = metrics.New()
+		if err := serverMetrics.Register(db.StatsCollector()); err != nil {
+			fatal("failed to register database metrics", err)
+		}
+		schema.AddExtensions(serverMetrics.GraphQLExtension())
+	}
+	if cfg.Tracing.Enabled() {
+		tracing.InstrumentSchema(&schema, otel.GetTracerProvider())
 	}
 
 	// Create GraphQL handler
 	graphqlHandler := handler.New(&handler.Config{
-		Schema:   &schema,
-		Pretty:   true,
-		GraphiQL: true, // Enable GraphiQL for development
+		Schema:        &schema,
+		Pretty:        cfg.GraphQL.Pretty,
+		GraphiQL:      cfg.GraphQL.GraphiQL,
+		FormatErrorFn: apperr.Format,
 	})
 
+	// Relay articles published by other server instances to our subscribers
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	defer stopListening()
+	go func() {
+		if err := db.ListenArticleCreated(listenCtx, resolver.RelayArticleCreated); err != nil {
+			slog.Warn("article notifications disabled", "error", err)
+		}
+	}()
+
+	// Answer repeated article listings from memory until articles change
+	var httpHandler http.Handler = graphqlHandler
+	if cfg.QueryCache.Size > 0 {
+		queryCache := graph.NewQueryCache(cache.NewMemoryStore(cfg.QueryCache.Size), cfg.QueryCache.TTL)
+		resolver.SetQueryCache(queryCache)
+		httpHandler = queryCache.Middleware(httpHandler)
+	}
+
+	// Let browsers and proxies cache GET persisted queries until articles change
+	httpHandler = graph.CacheQueries(db.ContentModifiedAt, cfg.GraphQL.CacheMaxAge)(httpHandler)
+
+	// Keep the schema private unless introspection is enabled
+	if !cfg.GraphQL.Introspection {
+		httpHandler = graph.DisableIntrospection(httpHandler)
+	}
+
+	// Serve subscriptions over WebSocket on the same endpoint
+	wsHandler := graph.NewWebSocketHandler(&schema, httpHandler)
+	wsHandler.DisableIntrospection = !cfg.GraphQL.Introspection
+
+	// Authenticate API keys, and bearer tokens when a signing key is configured
+	credentials := &auth.Credentials{APIKeys: db}
+	authConfig := auth.Config{
+		HMACSecret:       cfg.Auth.HMACSecret,
+		RSAPublicKeyFile: cfg.Auth.RSAPublicKeyFile,
+		JWKSFile:         cfg.Auth.JWKSFile,
+		Issuer:           cfg.Auth.Issuer,
+		Audience:         cfg.Auth.Audience,
+	}
+	if authConfig.Enabled() {
+		credentials.Tokens, err = auth.NewVerifier(authConfig)
+		if err != nil {
+			fatal("failed to configure authentication", err)
+		}
+	} else {
+		slog.Warn("no JWT signing key configured, only API keys are accepted")
+	}
+	wsHandler.InitFunc = credentials.ConnectionInit
+
+	// Allow browsers on the configured origins; WebSockets follow the same allowlist
+	corsConfig := middleware.CORSConfig{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   []string{"Retry-After", logging.RequestIDHeader},
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	}
+	wsHandler.CheckOrigin = corsConfig.CheckOrigin
+
+	// Throttle each client; a limit of 0 disables it
+	limits := ratelimit.Limits{
+		Query:    ratelimit.PerMinute(cfg.RateLimit.QueriesPerMinute),
+		Mutation: ratelimit.PerMinute(cfg.RateLimit.MutationsPerMinute),
+	}
+	limitStore := ratelimit.NewMemoryStore()
+	limitedHandler := ratelimit.Middleware(limitStore, limits)(wsHandler)
+	// Operations sent over an open WebSocket are counted one by one
+	wsHandler.LimitOperation = ratelimit.Operations(limitStore, limits)
+
+	// Look up the documents of persisted queries, the only cacheable ones
+	var persistedQueries graph.PersistedQueries
+	if cfg.GraphQL.PersistedQueriesFile != "" {
+		persistedQueries, err = graph.LoadPersistedQueries(cfg.GraphQL.PersistedQueriesFile)
+		if err != nil {
+			fatal("failed to load persisted queries", err)
+		}
+	}
+	persistedHandler := persistedQueries.Middleware(limitedHandler)
+
+	// Split batched requests into operations that are each limited and cached
+	batchHandler := graph.Batch(cfg.GraphQL.MaxBatchSize, cfg.GraphQL.BatchConcurrency)(persistedHandler)
+
+	// Bound request bodies once, before the middlewares above buffer them
+	boundedHandler := graph.LimitRequestBody(int64(cfg.GraphQL.MaxRequestSize))(batchHandler)
+
+	// Accept files sent as GraphQL multipart requests by authors, charged as
+	// a mutation before the files are buffered
+	uploadHandler := graph.MultipartRequests(int64(cfg.Uploads.MaxRequestSize), int64(cfg.Uploads.MaxFileSize),
+		ratelimit.Operations(limitStore, limits))(boundedHandler)
+	apiHandler := auth.Middleware(credentials)(uploadHandler)
+
+	// Throttle each IP address before its credentials are looked up
+	proxies, err := ratelimit.ParseProxies(cfg.RateLimit.TrustedProxies)
+	if err != nil {
+		fatal("failed to configure trusted proxies", err)
+	}
+	apiHandler = ratelimit.Clients(limitStore, ratelimit.PerMinute(cfg.RateLimit.RequestsPerMinute), proxies)(apiHandler)
+
 	// Setup routes
 	router := mux.NewRouter()
-	router.Handle("/graphql", graphqlHandler)
-
-	// Health check endpoint
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	router.Handle("/graphql", apiHandler)
+	// Images of unpublished articles are only served to those who may edit them
+	imagesHandler := http.StripPrefix("/images", resolver.ImageAccess(images.Handler()))
+	router.PathPrefix("/images/").Handler(auth.Middleware(credentials)(imagesHandler))
 
-	// CORS middleware for development
-	corsHandler := func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	// Liveness and readiness probes; /health is kept for existing health checks
+	router.Handle("/livez", health.Live())
+	router.Handle("/health", health.Live())
+	router.Handle("/readyz", health.Ready(map[string]health.Check{
+		"database":   db.PingContext,
+		"migrations": db.CheckMigrations,
+	}, 2*time.Second))
 
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
+	// Trace GraphQL requests, continuing the trace of the caller's traceparent.
+	// Subscriptions are left out, their connection lasts indefinitely.
+	if cfg.Tracing.Enabled() {
+		router.Use(otelmux.Middleware(cfg.Tracing.ServiceName, otelmux.WithFilter(func(r *http.Request) bool {
+			return r.URL.Path == "/graphql" && !websocket.IsWebSocketUpgrade(r)
+		})))
+	}
+	if serverMetrics != nil {
+		router.Use(serverMetrics.Middleware)
+		router.Handle("/metrics", serverMetrics.Handler())
+	}
 
-			next.ServeHTTP(w, r)
-		})
+	rootHandler := middleware.CORS(corsConfig)(router)
+	if cfg.Server.Compression {
+		rootHandler = middleware.Compress(rootHandler)
 	}
 
 	// Create server
 	server := &http.Server{
-		Addr:         ":8080",
-		Handler:      corsHandler(router),
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:         cfg.Server.Addr,
+		Handler:      logging.Middleware(logger)(rootHandler),
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	// Serve HTTPS, which negotiates HTTP/2 as well, when a certificate is
+	// configured. Rotated certificates are picked up on SIGHUP.
+	scheme, wsScheme := "http", "ws"
+	if cfg.Server.TLSEnabled() {
+		certs, err := tlscert.NewReloader(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		if err != nil {
+			fatal("failed to load TLS certificate", err)
+		}
+		server.TLSConfig = certs.TLSConfig()
+		scheme, wsScheme = "https", "wss"
+		go reloadOnSIGHUP(certs)
+	} else if cfg.Server.H2C {
+		// Accept HTTP/2 with prior knowledge next to HTTP/1.1
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetUnencryptedHTTP2(true)
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Println("Server starting on :8080")
-		log.Println("GraphQL endpoint: http://localhost:8080/graphql")
-		log.Println("GraphiQL UI: http://localhost:8080/graphql")
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+		slog.Info("server starting",
+			"addr", cfg.Server.Addr,
+			"graphql", scheme+"://"+displayAddr(cfg.Server.Addr)+"/graphql",
+			"graphiql", cfg.GraphQL.GraphiQL,
+			"introspection", cfg.GraphQL.Introspection,
+			"subscriptions", wsScheme+"://"+displayAddr(cfg.Server.Addr)+"/graphql",
+			"h2c", cfg.Server.H2C)
+
+		var err error
+		if cfg.Server.TLSEnabled() {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("server failed to start", err)
 		}
 	}()
 
@@ -91,15 +291,43 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	slog.Info("shutting down server")
 
 	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal("server forced to shutdown", err)
 	}
 
-	log.Println("Server exited")
+	slog.Info("server exited")
+}
+
+// fatal logs err and exits. Deferred functions do not run.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// reloadOnSIGHUP reloads the TLS certificate whenever the process receives
+// SIGHUP, keeping the current one if the new files are invalid.
+func reloadOnSIGHUP(certs *tlscert.Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := certs.Reload(); err != nil {
+			slog.Error("failed to reload TLS certificate, keeping the previous one", "error", err)
+			continue
+		}
+		slog.Info("TLS certificate reloaded", "expires", certs.Certificate().Leaf.NotAfter)
+	}
+}
+
+// displayAddr turns a listen address such as ":8080" into one to browse to.
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
 }
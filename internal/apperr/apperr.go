@@ -0,0 +1,218 @@
+// Package apperr defines the typed errors returned by resolvers. Each error
+// carries a machine readable code that is exposed to clients in the
+// extensions of a GraphQL error, while internal details stay in the logs.
+package apperr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/location"
+)
+
+type Code string
+
+const (
+	CodeBadUserInput    Code = "BAD_USER_INPUT"
+	CodeNotFound        Code = "NOT_FOUND"
+	CodeConflict        Code = "CONFLICT"
+	CodeInternal        Code = "INTERNAL"
+	CodeUnauthenticated Code = "UNAUTHENTICATED"
+	CodeForbidden       Code = "FORBIDDEN"
+	CodeRateLimited     Code = "RATE_LIMITED"
+	CodeTimeout         Code = "TIMEOUT"
+	CodeCanceled        Code = "CANCELED"
+)
+
+// internalMessage replaces the message of internal errors sent to clients.
+const internalMessage = "internal server error"
+
+type Error struct {
+	Code    Code
+	Message string
+	// Field is the path to the offending argument or input field, e.g. ["input", "title"]
+	Field []string
+	// Err is the underlying cause. It is logged but never sent to clients.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Extensions implements gqlerrors.ExtendedError.
+func (e *Error) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{
+		"code": e.Code,
+	}
+	if len(e.Field) > 0 {
+		extensions["field"] = e.Field
+	}
+	return extensions
+}
+
+// Errors collects several errors, typically one per invalid input field, so
+// that they can all be reported at once.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func BadUserInput(message string, field ...string) *Error {
+	return &Error{Code: CodeBadUserInput, Message: message, Field: field}
+}
+
+func NotFound(message string) *Error {
+	return &Error{Code: CodeNotFound, Message: message}
+}
+
+func Conflict(message string, field ...string) *Error {
+	return &Error{Code: CodeConflict, Message: message, Field: field}
+}
+
+func Unauthenticated(message string) *Error {
+	return &Error{Code: CodeUnauthenticated, Message: message}
+}
+
+func Forbidden(message string) *Error {
+	return &Error{Code: CodeForbidden, Message: message}
+}
+
+func RateLimited(message string) *Error {
+	return &Error{Code: CodeRateLimited, Message: message}
+}
+
+// Timeout wraps a database call that ran into the statement timeout.
+func Timeout(err error) *Error {
+	return &Error{Code: CodeTimeout, Message: "the operation took too long and was aborted", Err: err}
+}
+
+// Canceled wraps a call that was abandoned because the client went away.
+func Canceled(err error) *Error {
+	return &Error{Code: CodeCanceled, Message: "the operation was canceled", Err: err}
+}
+
+// Internal wraps an unexpected error, such as a failed database call.
+func Internal(err error) *Error {
+	return &Error{Code: CodeInternal, Message: internalMessage, Err: err}
+}
+
+// As returns the *Error in err's chain. Errors that are not typed are
+// reported as internal errors.
+func As(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(err)
+}
+
+// Format turns an execution error into the error sent to clients. Errors raised
+// by resolvers get their code and field extensions, and internal errors are
+// logged and masked. Parse and validation errors are passed through unchanged.
+// It is meant to be used as handler.Config.FormatErrorFn.
+func Format(err error) gqlerrors.FormattedError {
+	if err == nil {
+		return gqlerrors.NewFormattedError(internalMessage)
+	}
+
+	var located *gqlerrors.Error
+	if !errors.As(err, &located) || located.OriginalError == nil {
+		// Execution gives up on its own once the request context is done, and
+		// requests may be rejected before execution
+		var appErr *Error
+		switch {
+		case errors.Is(err, context.Canceled):
+			return Canceled(err).formatted()
+		case errors.Is(err, context.DeadlineExceeded):
+			return Timeout(err).formatted()
+		case errors.As(err, &appErr):
+			return appErr.formatted()
+		}
+		return gqlerrors.FormatError(err)
+	}
+
+	appErr := As(located.OriginalError)
+	formatted := gqlerrors.FormatError(located)
+	formatted.Message = appErr.Message
+	formatted.Extensions = appErr.Extensions()
+
+	switch appErr.Code {
+	case CodeInternal:
+		slog.Error("internal error", "path", formatted.Path, "error", appErr.Err)
+	case CodeTimeout:
+		slog.Warn("operation timed out", "path", formatted.Path, "error", appErr.Err)
+	}
+
+	return formatted
+}
+
+// CodeOf returns the code Format reports for an execution error, without
+// logging it. Parse and validation errors have no code.
+func CodeOf(err error) Code {
+	var located *gqlerrors.Error
+	if errors.As(err, &located) && located.OriginalError != nil {
+		return As(located.OriginalError).Code
+	}
+
+	var appErr *Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.As(err, &appErr):
+		return appErr.Code
+	}
+	return ""
+}
+
+// FormatAll applies Format to the errors of an executed result.
+func FormatAll(errs []gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	formatted := make([]gqlerrors.FormattedError, len(errs))
+	for i, err := range errs {
+		if original := err.OriginalError(); original != nil {
+			formatted[i] = Format(original)
+		} else {
+			formatted[i] = err
+		}
+	}
+	return formatted
+}
+
+// WriteHTTP rejects a request before it reaches GraphQL execution, replying
+// with status and a GraphQL response holding only err.
+func WriteHTTP(w http.ResponseWriter, status int, err *Error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []gqlerrors.FormattedError{err.formatted()},
+	})
+}
+
+// formatted returns e as an error without location, for errors raised
+// outside of field resolution.
+func (e *Error) formatted() gqlerrors.FormattedError {
+	return gqlerrors.FormattedError{
+		Message:    e.Message,
+		Locations:  []location.SourceLocation{},
+		Extensions: e.Extensions(),
+	}
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config holds the keys used to validate bearer tokens. At least one of
+// HMACSecret, RSAPublicKeyFile or JWKSFile has to be set.
+type Config struct {
+	// HMACSecret validates HS256 tokens.
+	HMACSecret string
+	// RSAPublicKeyFile is a PEM encoded public key validating RS256 tokens.
+	RSAPublicKeyFile string
+	// JWKSFile is a local JSON Web Key Set whose RSA keys validate RS256 tokens,
+	// selected by the kid header of the token.
+	JWKSFile string
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+}
+
+// Enabled reports whether any key is configured.
+func (c Config) Enabled() bool {
+	return c.HMACSecret != "" || c.RSAPublicKeyFile != "" || c.JWKSFile != ""
+}
+
+// Verifier validates JWTs and turns their claims into a Principal.
+type Verifier struct {
+	hmacSecret []byte
+	// rsaKeys are indexed by key ID; a key without ID is stored under "".
+	rsaKeys map[string]*rsa.PublicKey
+	parser  *jwt.Parser
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+	// Roles may be given as a list in "roles" or as a single "role".
+	Roles []string `json:"roles"`
+	Role  string   `json:"role"`
+}
+
+func NewVerifier(cfg Config) (*Verifier, error) {
+	if !cfg.Enabled() {
+		return nil, errors.New("no token signing key configured")
+	}
+
+	v := &Verifier{rsaKeys: make(map[string]*rsa.PublicKey)}
+	var methods []string
+
+	if cfg.HMACSecret != "" {
+		v.hmacSecret = []byte(cfg.HMACSecret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	if cfg.RSAPublicKeyFile != "" {
+		pem, err := os.ReadFile(cfg.RSAPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RSA public key: %w", err)
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA public key: %w", err)
+		}
+		v.rsaKeys[""] = key
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		for kid, key := range keys {
+			v.rsaKeys[kid] = key
+		}
+	}
+
+	if len(v.rsaKeys) > 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(options...)
+
+	return v, nil
+}
+
+// Verify validates the signature and claims of token and returns the caller.
+func (v *Verifier) Verify(token string) (*Principal, error) {
+	var c claims
+	if _, err := v.parser.ParseWithClaims(token, &c, v.key); err != nil {
+		return nil, err
+	}
+
+	if c.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	if strings.HasPrefix(c.Subject, APIKeySubjectPrefix) {
+		return nil, errors.New("token subject is reserved for API keys")
+	}
+
+	name := c.Name
+	if name == "" {
+		name = c.PreferredUsername
+	}
+	if name == "" {
+		name = c.Subject
+	}
+
+	return &Principal{
+		Subject: c.Subject,
+		Name:    name,
+		Email:   c.Email,
+		Roles:   parseRoles(append(c.Roles, c.Role)),
+	}, nil
+}
+
+// parseRoles keeps the known roles of a token. Callers without any known role
+// are readers.
+func parseRoles(names []string) []Role {
+	var roles []Role
+	for _, name := range names {
+		if role, ok := ParseRole(name); ok {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		roles = []Role{RoleReader}
+	}
+	return roles
+}
+
+func (v *Verifier) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return v.hmacSecret, nil
+	case jwt.SigningMethodRS256.Alg():
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := v.rsaKeys[kid]; ok {
+			return key, nil
+		}
+		// Tokens without kid are accepted when there is only one key to try
+		if kid == "" && len(v.rsaKeys) == 1 {
+			for _, key := range v.rsaKeys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// loadJWKS reads the RSA signing keys of a JSON Web Key Set file.
+func loadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent of key %q: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS %s contains no RSA signing keys", path)
+	}
+	return keys, nil
+}
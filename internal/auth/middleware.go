@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/logging"
+)
+
+// ErrInvalidCredentials is returned for tokens and API keys that are malformed,
+// expired, revoked or unknown.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Credentials authenticates the credentials sent in an Authorization header:
+// API keys, recognised by APIKeyPrefix, and JWTs.
+type Credentials struct {
+	// Tokens validates JWTs; nil when JWTs are not accepted.
+	Tokens *Verifier
+	// APIKeys looks up API keys; nil when API keys are not accepted.
+	APIKeys APIKeyStore
+}
+
+// Authenticate returns the caller identified by a bearer token. Errors other
+// than ErrInvalidCredentials mean the credentials could not be checked.
+func (c *Credentials) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	if IsAPIKey(token) {
+		if c.APIKeys == nil {
+			return nil, fmt.Errorf("%w: API keys are not accepted", ErrInvalidCredentials)
+		}
+		return authenticateAPIKey(ctx, c.APIKeys, token)
+	}
+
+	if c.Tokens == nil {
+		return nil, fmt.Errorf("%w: bearer tokens are not accepted", ErrInvalidCredentials)
+	}
+	principal, err := c.Tokens.Verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return principal, nil
+}
+
+// Middleware authenticates requests carrying an "Authorization: Bearer"
+// token or API key and stores the principal in the request context. Requests
+// without credentials pass through anonymously; requests with invalid
+// credentials are rejected.
+func Middleware(c *Credentials) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := BearerToken(r.Header.Get("Authorization"))
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := c.Authenticate(r.Context(), token)
+			if errors.Is(err, ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				apperr.WriteHTTP(w, http.StatusUnauthorized, apperr.Unauthenticated("invalid bearer token"))
+				return
+			}
+			if err != nil {
+				logging.FromContext(r.Context()).Error("failed to authenticate request", "error", err)
+				apperr.WriteHTTP(w, http.StatusInternalServerError, apperr.Internal(err))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// BearerToken extracts the token of an Authorization header value.
+func BearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// ConnectionInit authenticates a WebSocket connection from the Authorization
+// entry of its connection_init payload, since browsers cannot set headers on
+// WebSocket requests. Connections without a token stay anonymous.
+func (c *Credentials) ConnectionInit(ctx context.Context, payload map[string]interface{}) (context.Context, error) {
+	for key, value := range payload {
+		if !strings.EqualFold(key, "Authorization") {
+			continue
+		}
+
+		header, _ := value.(string)
+		token, ok := BearerToken(header)
+		if !ok {
+			return nil, errors.New("malformed authorization")
+		}
+
+		principal, err := c.Authenticate(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		return WithPrincipal(ctx, principal), nil
+	}
+
+	return ctx, nil
+}
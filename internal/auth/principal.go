@@ -0,0 +1,69 @@
+// Package auth authenticates API callers and carries their identity through
+// the request context.
+package auth
+
+import (
+	"context"
+	"strings"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string
+	Name    string
+	Email   string
+	Roles   []Role
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal of the request, if it is authenticated.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// Role grants a set of permissions. Roles are ordered: each role includes the
+// permissions of the roles before it.
+type Role string
+
+const (
+	RoleReader    Role = "reader"
+	RoleAuthor    Role = "author"
+	RolePublisher Role = "publisher"
+	RoleEditor    Role = "editor"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleReader:    1,
+	RoleAuthor:    2,
+	RolePublisher: 3,
+	RoleEditor:    4,
+	RoleAdmin:     5,
+}
+
+// ParseRole returns the role named s, ignoring case.
+func ParseRole(s string) (Role, bool) {
+	role := Role(strings.ToLower(strings.TrimSpace(s)))
+	_, ok := roleRank[role]
+	return role, ok
+}
+
+// HasRole reports whether the principal has role or a role above it.
+func (p *Principal) HasRole(role Role) bool {
+	for _, r := range p.Roles {
+		if roleRank[r] >= roleRank[role] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+// Package cache keeps the results of frequent GraphQL queries, such as the
+// article listing of the homepage, so that they skip the database.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps cached results by key. Implementations backed by a shared
+// cache let several server instances share results and invalidations.
+type Store interface {
+	// Get returns the value stored under key, unless it expired.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Purge drops every entry, once the data they were computed from changed.
+	Purge(ctx context.Context) error
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// MemoryStore keeps up to a fixed number of entries in memory, evicting the
+// least recently used one when full. Entries are per process, so every
+// server instance has its own.
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	// recency holds the entries, most recently used first.
+	recency *list.List
+}
+
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		recency:  list.New(),
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := element.Value.(*entry)
+	if time.Now().After(e.expires) {
+		s.remove(element)
+		return nil, false, nil
+	}
+	s.recency.MoveToFront(element)
+	return e.value, true, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if element, ok := s.entries[key]; ok {
+		e := element.Value.(*entry)
+		e.value, e.expires = value, expires
+		s.recency.MoveToFront(element)
+		return nil
+	}
+
+	s.entries[key] = s.recency.PushFront(&entry{key: key, value: value, expires: expires})
+	for s.recency.Len() > s.capacity {
+		s.remove(s.recency.Back())
+	}
+	return nil
+}
+
+func (s *MemoryStore) Purge(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = make(map[string]*list.Element)
+	s.recency.Init()
+	return nil
+}
+
+// Len returns the number of entries, expired ones included until they are
+// looked up or evicted.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.recency.Len()
+}
+
+func (s *MemoryStore) remove(element *list.Element) {
+	s.recency.Remove(element)
+	delete(s.entries, element.Value.(*entry).key)
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from the defaults, the YAML file given by the
+// -config flag or CONFIG_FILE variable, the environment (including a .env
+// file, if present) and the flags in args, then validates it.
+func Load(args []string) (*Config, error) {
+	// Variables already set in the environment take precedence over .env
+	_ = godotenv.Load()
+
+	cfg := Default()
+	settings := cfg.settings()
+
+	// Flags are applied last, so only record them while parsing
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	var overrides []func() error
+	for _, s := range settings {
+		s := s
+		flags.Var(&flagValue{setting: s, record: func(value string) {
+			overrides = append(overrides, func() error { return s.set(value) })
+		}}, s.path, "overrides "+s.env)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok {
+			if err := s.set(value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, override := range overrides {
+		if err := override(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// setting is a single configurable value of Config.
+type setting struct {
+	// path is the dotted YAML path, which is also the flag name.
+	path  string
+	env   string
+	value reflect.Value
+}
+
+func (c *Config) settings() []setting {
+	var settings []setting
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			path := prefix + field.Tag.Get("yaml")
+			if field.Type.Kind() == reflect.Struct {
+				walk(path+".", v.Field(i))
+				continue
+			}
+			settings = append(settings, setting{path: path, env: field.Tag.Get("env"), value: v.Field(i)})
+		}
+	}
+	walk("", reflect.ValueOf(c).Elem())
+	return settings
+}
+
+// set parses s into the setting. Lists are comma separated and durations use
+// the time.ParseDuration format, e.g. "15s".
+func (s setting) set(value string) error {
+	switch s.value.Interface().(type) {
+	case string:
+		s.value.SetString(value)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.path, err)
+		}
+		s.value.SetBool(b)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.path, err)
+		}
+		s.value.SetInt(int64(n))
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.path, err)
+		}
+		s.value.SetInt(int64(d))
+	case []string:
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("%s: unsupported setting type %s", s.path, s.value.Type())
+	}
+	return nil
+}
+
+// flagValue records a flag for a setting, to be applied after the file and
+// the environment.
+type flagValue struct {
+	setting setting
+	record  func(string)
+}
+
+func (f *flagValue) String() string {
+	if f == nil || !f.setting.value.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(f.setting.value.Interface())
+}
+
+func (f *flagValue) Set(value string) error {
+	// Check the value now, so that errors are reported like other flag errors
+	probe := setting{path: f.setting.path, value: reflect.New(f.setting.value.Type()).Elem()}
+	if err := probe.set(value); err != nil {
+		return err
+	}
+	f.record(value)
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.setting.value.Kind() == reflect.Bool
+}
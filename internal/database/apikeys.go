@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/models"
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = "id, name, prefix, scopes, created_at, last_used_at, revoked_at"
+
+// CreateAPIKey stores a new API key. Only the hash of the key is stored, so the
+// key itself cannot be recovered later.
+func (db *DB) CreateAPIKey(ctx context.Context, name, prefix, hash string, scopes []string) (*models.APIKey, error) {
+	row := db.QueryRowContext(ctx, `
+        INSERT INTO api_keys (name, prefix, key_hash, scopes)
+        VALUES ($1, $2, $3, $4)
+        RETURNING `+apiKeyColumns,
+		name, prefix, hash, pq.Array(scopes))
+
+	key, err := scanAPIKey(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns all API keys, including revoked ones, newest first.
+func (db *DB) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY created_at DESC, id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey revokes the API key with the given ID. Revoking a key twice
+// keeps the original revocation time. It returns sql.ErrNoRows when there is
+// no such key.
+func (db *DB) RevokeAPIKey(ctx context.Context, id int) (*models.APIKey, error) {
+	row := db.QueryRowContext(ctx, `
+        UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW())
+        WHERE id = $1
+        RETURNING `+apiKeyColumns, id)
+
+	key, err := scanAPIKey(row)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return key, nil
+}
+
+// AuthenticateAPIKey returns the active API key with the given hash and
+// records that it was used. It returns sql.ErrNoRows for unknown or revoked keys.
+//
+// The time of use is only written once a minute, so that authenticating every
+// request does not cost a write.
+func (db *DB) AuthenticateAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	row := db.QueryRowContext(ctx, `
+        WITH found AS (
+            SELECT `+apiKeyColumns+` FROM api_keys
+            WHERE key_hash = $1 AND revoked_at IS NULL
+        ), touched AS (
+            UPDATE api_keys k SET last_used_at = NOW()
+            FROM found f
+            WHERE k.id = f.id AND (f.last_used_at IS NULL OR f.last_used_at < NOW() - INTERVAL '1 minute')
+            RETURNING k.id, k.last_used_at
+        )
+        SELECT f.id, f.name, f.prefix, f.scopes, f.created_at,
+            COALESCE(t.last_used_at, f.last_used_at), f.revoked_at
+        FROM found f
+        LEFT JOIN touched t ON t.id = f.id`, hash)
+
+	key, err := scanAPIKey(row)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	return key, nil
+}
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&key.Scopes),
+		&key.CreatedAt, &key.LastUsedAt, &key.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
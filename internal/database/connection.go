@@ -1,50 +1,183 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type DB struct {
 	*sql.DB
+	dsn string
+	// migrated is set once RunMigrations succeeded.
+	migrated atomic.Bool
+	// slowQueryThreshold is set by SetSlowQueryThreshold.
+	slowQueryThreshold time.Duration
 }
 
-func NewConnection() (*DB, error) {
-	// Load .env file
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found or failed to load, proceeding with system environment variables.")
-	}
-
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
+// Connection attempts back off exponentially from initialRetryDelay up to
+// maxRetryDelay between attempts.
+const (
+	initialRetryDelay = 500 * time.Millisecond
+	maxRetryDelay     = 10 * time.Second
+	pingTimeout       = 5 * time.Second
+)
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
+func NewConnection(cfg config.Database) (*DB, error) {
+	psqlInfo, err := DSN(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := sql.Open("postgres", psqlInfo)
+	db, err := otelsql.Open("postgres", psqlInfo,
+		otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+			// Only trace statements issued on behalf of a traced request,
+			// not migrations or the listener
+			SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// Configure connection pool for high concurrency
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	// Test the connection, waiting for a database that is still starting
+	if err := pingWithRetry(db, cfg.ConnectTimeout); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	slog.Info("connected to PostgreSQL")
+	return &DB{DB: db, dsn: psqlInfo}, nil
+}
+
+// pingWithRetry pings db until it answers or timeout has passed.
+func pingWithRetry(db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	delay := initialRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("failed to ping database after %d attempt(s): %w", attempt, err)
+		}
+		slog.Warn("database not reachable, retrying", "attempt", attempt, "retry_in", delay.String(), "error", err)
+		time.Sleep(delay)
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// CheckMigrations reports whether RunMigrations completed, for readiness probes.
+func (db *DB) CheckMigrations(ctx context.Context) error {
+	if !db.migrated.Load() {
+		return errors.New("migrations have not been applied")
+	}
+	return nil
+}
+
+// StatsCollector exports the connection pool statistics, such as the open
+// and in use connections and how often callers had to wait for one.
+func (db *DB) StatsCollector() prometheus.Collector {
+	return collectors.NewDBStatsCollector(db.DB, "articles")
+}
+
+// DSN builds the connection string for cfg. The TLS settings and statement
+// timeout of cfg take precedence over the parameters of cfg.URL.
+func DSN(cfg config.Database) (string, error) {
+	settings := map[string]string{
+		"sslmode":     cfg.SSLMode,
+		"sslrootcert": cfg.SSLRootCert,
+		"sslcert":     cfg.SSLCert,
+		"sslkey":      cfg.SSLKey,
+	}
+	// Unknown parameters are sent to the server as session settings
+	if cfg.StatementTimeout > 0 {
+		settings["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	if cfg.URL != "" {
+		u, err := url.Parse(cfg.URL)
+		if err != nil {
+			return "", fmt.Errorf("invalid database URL: %w", err)
+		}
+		query := u.Query()
+		for key, value := range settings {
+			if value != "" {
+				query.Set(key, value)
+			}
+		}
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	if settings["sslmode"] == "" {
+		settings["sslmode"] = "disable"
 	}
+	params := map[string]string{
+		"host":     cfg.Host,
+		"port":     strconv.Itoa(cfg.Port),
+		"user":     cfg.User,
+		"password": cfg.Password,
+		"dbname":   cfg.Name,
+	}
+	for key, value := range settings {
+		params[key] = value
+	}
+
+	keys := make([]string, 0, len(params))
+	for key, value := range params {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
 
-	log.Println("Successfully connected to PostgreSQL database")
-	return &DB{db}, nil
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + quoteDSNValue(params[key])
+	}
+	return strings.Join(pairs, " "), nil
+}
+
+// quoteDSNValue quotes values containing spaces or quotes, e.g. passwords,
+// the way libpq key/value connection strings expect.
+func quoteDSNValue(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + escaped + "'"
 }
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// PostgreSQL error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+	queryCanceled       = "57014"
+)
+
+// IsUniqueViolation reports whether err was caused by a UNIQUE constraint.
+func IsUniqueViolation(err error) bool {
+	return hasCode(err, uniqueViolation)
+}
+
+// IsForeignKeyViolation reports whether err was caused by a FOREIGN KEY constraint,
+// e.g. when deleting a row that is still referenced.
+func IsForeignKeyViolation(err error) bool {
+	return hasCode(err, foreignKeyViolation)
+}
+
+func hasCode(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
+
+// IsTimeout reports whether err was caused by the statement timeout or an
+// expired context deadline.
+func IsTimeout(err error) bool {
+	return hasCode(err, queryCanceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/models"
+	"github.com/lib/pq"
+)
+
+const imageColumns = "id, article_id, key, content_type, size, created_at"
+
+// CreateArticleImage records an image stored under key for an article. It
+// fails with a foreign key violation when the article does not exist.
+func (db *DB) CreateArticleImage(ctx context.Context, articleID int, key, contentType string, size int64) (*models.Image, error) {
+	row := db.QueryRowContext(ctx, `
+        INSERT INTO article_images (article_id, key, content_type, size)
+        VALUES ($1, $2, $3, $4)
+        RETURNING `+imageColumns,
+		articleID, key, contentType, size)
+
+	image, err := scanImage(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create article image: %w", err)
+	}
+	return image, nil
+}
+
+// ListArticleImages returns the images of several articles at once, by
+// article ID, oldest first.
+func (db *DB) ListArticleImages(ctx context.Context, articleIDs []int) (map[int][]*models.Image, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+imageColumns+" FROM article_images WHERE article_id = ANY($1) ORDER BY created_at, id", pq.Array(articleIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list article images: %w", err)
+	}
+	defer rows.Close()
+
+	images := make(map[int][]*models.Image, len(articleIDs))
+	for rows.Next() {
+		image, err := scanImage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan article image: %w", err)
+		}
+		images[image.ArticleID] = append(images[image.ArticleID], image)
+	}
+	return images, rows.Err()
+}
+
+func scanImage(row interface{ Scan(...interface{}) error }) (*models.Image, error) {
+	var image models.Image
+	err := row.Scan(&image.ID, &image.ArticleID, &image.Key, &image.ContentType, &image.Size, &image.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
@@ -2,17 +2,31 @@ package database
 
 import (
     "fmt"
+    "log/slog"
 )
 
 func (db *DB) RunMigrations() error {
-    // Create authors table
+    // Create authors table. Authors are identified by the subject of their
+    // token or API key; names are only displayed and may repeat. Authors created
+    // before subjects were recorded have none.
     createAuthorsTable := `
     CREATE TABLE IF NOT EXISTS authors (
         id SERIAL PRIMARY KEY,
-        name VARCHAR(255) NOT NULL UNIQUE,
-        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        subject VARCHAR(255) UNIQUE,
+        name VARCHAR(255) NOT NULL,
+        email VARCHAR(255),
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
     );`
     
+    // Add columns introduced after the tables were first created
+    addColumns := []string{
+        "ALTER TABLE authors ADD COLUMN IF NOT EXISTS email VARCHAR(255);",
+        "ALTER TABLE authors ADD COLUMN IF NOT EXISTS subject VARCHAR(255) UNIQUE;",
+    }
+    
+    // Author names used to identify authors, so they had to be unique
+    dropAuthorNameUniqueness := "ALTER TABLE authors DROP CONSTRAINT IF EXISTS authors_name_key;"
+    
     // Create articles table with proper indexing
     createArticlesTable := `
     CREATE TABLE IF NOT EXISTS articles (
@@ -20,16 +34,104 @@ func (db *DB) RunMigrations() error {
         title VARCHAR(500) NOT NULL,
         body TEXT NOT NULL,
         author_id INTEGER NOT NULL REFERENCES authors(id),
-        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        status VARCHAR(20) NOT NULL DEFAULT 'DRAFT' CHECK (status IN ('DRAFT', 'IN_REVIEW', 'PUBLISHED', 'ARCHIVED')),
+        published_at TIMESTAMPTZ
+    );`
+    
+    // Create API keys table. Only a hash of each key is stored; prefix is the
+    // start of the key, kept so admins can tell keys apart.
+    createAPIKeysTable := `
+    CREATE TABLE IF NOT EXISTS api_keys (
+        id SERIAL PRIMARY KEY,
+        name VARCHAR(255) NOT NULL,
+        prefix VARCHAR(16) NOT NULL,
+        key_hash CHAR(64) NOT NULL UNIQUE,
+        scopes TEXT[] NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        last_used_at TIMESTAMPTZ,
+        revoked_at TIMESTAMPTZ
+    );`
+    
+    // Create article images table. Files live in the storage backend under
+    // key; rows go away with their article.
+    createArticleImagesTable := `
+    CREATE TABLE IF NOT EXISTS article_images (
+        id SERIAL PRIMARY KEY,
+        article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+        key VARCHAR(255) NOT NULL UNIQUE,
+        content_type VARCHAR(255) NOT NULL,
+        size BIGINT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
     );`
     
+    // Track when articles or authors last changed, so that responses can be
+    // cached until then. Statement triggers keep the single row up to date,
+    // deletions included.
+    createModificationsTable := `
+    CREATE TABLE IF NOT EXISTS content_modifications (
+        id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+        modified_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+    INSERT INTO content_modifications DEFAULT VALUES ON CONFLICT DO NOTHING;
+    CREATE OR REPLACE FUNCTION touch_content_modifications() RETURNS trigger AS $$
+    BEGIN
+        UPDATE content_modifications SET modified_at = GREATEST(modified_at, clock_timestamp());
+        RETURN NULL;
+    END $$ LANGUAGE plpgsql;`
+    
+    createModificationTriggers := []string{}
+    for _, table := range []string{"authors", "articles", "article_images"} {
+        createModificationTriggers = append(createModificationTriggers, fmt.Sprintf(`
+    CREATE OR REPLACE TRIGGER %[1]s_modified
+        AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON %[1]s
+        FOR EACH STATEMENT EXECUTE FUNCTION touch_content_modifications();`, table))
+    }
+    
+    // Upgrade timestamps of tables created before TIMESTAMPTZ was used. Existing
+    // values are interpreted in the session time zone, like CURRENT_TIMESTAMP did.
+    upgradeTimestamps := []string{}
+    for _, table := range []string{"authors", "articles"} {
+        upgradeTimestamps = append(upgradeTimestamps, fmt.Sprintf(`
+    DO $$
+    BEGIN
+        IF EXISTS (
+            SELECT 1 FROM information_schema.columns
+            WHERE table_schema = current_schema() AND table_name = '%[1]s'
+              AND column_name = 'created_at' AND data_type = 'timestamp without time zone'
+        ) THEN
+            ALTER TABLE %[1]s ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+        END IF;
+    END $$;`, table))
+    }
+    
+    // Add the publishing workflow to articles created before it. Those articles
+    // were public, so they become published as of their creation.
+    upgradeArticleStatus := `
+    DO $$
+    BEGIN
+        IF NOT EXISTS (
+            SELECT 1 FROM information_schema.columns
+            WHERE table_schema = current_schema() AND table_name = 'articles'
+              AND column_name = 'status'
+        ) THEN
+            ALTER TABLE articles ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'PUBLISHED'
+                CHECK (status IN ('DRAFT', 'IN_REVIEW', 'PUBLISHED', 'ARCHIVED'));
+            ALTER TABLE articles ALTER COLUMN status SET DEFAULT 'DRAFT';
+            ALTER TABLE articles ADD COLUMN IF NOT EXISTS published_at TIMESTAMPTZ;
+            UPDATE articles SET published_at = created_at;
+        END IF;
+    END $$;`
+    
     // Create indexes for efficient querying
     createIndexes := []string{
         "CREATE INDEX IF NOT EXISTS idx_articles_created_at ON articles(created_at DESC);",
         "CREATE INDEX IF NOT EXISTS idx_articles_author_id ON articles(author_id);",
+        "CREATE INDEX IF NOT EXISTS idx_articles_status_created_at ON articles(status, created_at DESC);",
         "CREATE INDEX IF NOT EXISTS idx_articles_title_gin ON articles USING gin(to_tsvector('english', title));",
         "CREATE INDEX IF NOT EXISTS idx_articles_body_gin ON articles USING gin(to_tsvector('english', body));",
         "CREATE INDEX IF NOT EXISTS idx_authors_name ON authors(name);",
+        "CREATE INDEX IF NOT EXISTS idx_article_images_article_id ON article_images(article_id);",
     }
     
     // Execute migrations
@@ -41,12 +143,51 @@ func (db *DB) RunMigrations() error {
         return fmt.Errorf("failed to create articles table: %w", err)
     }
     
+    if _, err := db.Exec(createAPIKeysTable); err != nil {
+        return fmt.Errorf("failed to create api_keys table: %w", err)
+    }
+    
+    if _, err := db.Exec(createArticleImagesTable); err != nil {
+        return fmt.Errorf("failed to create article_images table: %w", err)
+    }
+    
+    for _, columnSQL := range addColumns {
+        if _, err := db.Exec(columnSQL); err != nil {
+            return fmt.Errorf("failed to add column: %w", err)
+        }
+    }
+    
+    if _, err := db.Exec(dropAuthorNameUniqueness); err != nil {
+        return fmt.Errorf("failed to drop author name uniqueness: %w", err)
+    }
+    
+    for _, upgradeSQL := range upgradeTimestamps {
+        if _, err := db.Exec(upgradeSQL); err != nil {
+            return fmt.Errorf("failed to upgrade timestamp columns: %w", err)
+        }
+    }
+    
+    if _, err := db.Exec(upgradeArticleStatus); err != nil {
+        return fmt.Errorf("failed to add article status: %w", err)
+    }
+    
     for _, indexSQL := range createIndexes {
         if _, err := db.Exec(indexSQL); err != nil {
             return fmt.Errorf("failed to create index: %w", err)
         }
     }
     
-    fmt.Println("Database migrations completed successfully")
+    if _, err := db.Exec(createModificationsTable); err != nil {
+        return fmt.Errorf("failed to create content_modifications table: %w", err)
+    }
+    
+    for _, triggerSQL := range createModificationTriggers {
+        if _, err := db.Exec(triggerSQL); err != nil {
+            return fmt.Errorf("failed to create modification trigger: %w", err)
+        }
+    }
+    
+    db.migrated.Store(true)
+    slog.Info("database migrations completed")
     return nil
 }
\ No newline at end of file
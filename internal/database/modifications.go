@@ -0,0 +1,17 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ContentModifiedAt returns when articles or authors were last created,
+// updated or deleted.
+func (db *DB) ContentModifiedAt(ctx context.Context) (time.Time, error) {
+	var modifiedAt time.Time
+	if err := db.QueryRowContext(ctx, "SELECT modified_at FROM content_modifications").Scan(&modifiedAt); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get content modification time: %w", err)
+	}
+	return modifiedAt, nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ArticleCreatedChannel is the LISTEN/NOTIFY channel used to announce newly
+// published articles to every server instance connected to the same database.
+const ArticleCreatedChannel = "article_created"
+
+// ArticleNotification is the payload sent on ArticleCreatedChannel.
+type ArticleNotification struct {
+	ID     int    `json:"id"`
+	Origin string `json:"origin"`
+}
+
+// NotifyArticleCreated queues a notification on the given transaction. PostgreSQL
+// only delivers it once the transaction commits.
+func NotifyArticleCreated(ctx context.Context, tx *Tx, n ArticleNotification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", ArticleCreatedChannel, string(payload)); err != nil {
+		return fmt.Errorf("failed to notify article creation: %w", err)
+	}
+	return nil
+}
+
+// ListenArticleCreated opens a dedicated connection that listens on
+// ArticleCreatedChannel and calls handle for every notification until ctx is done.
+func (db *DB) ListenArticleCreated(ctx context.Context, handle func(ArticleNotification)) error {
+	listener := pq.NewListener(db.dsn, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("article listener event", "event", event, "error", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(ArticleCreatedChannel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", ArticleCreatedChannel, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case notification := <-listener.Notify:
+			// A nil notification means the connection was re-established and
+			// notifications sent in the meantime may have been lost.
+			if notification == nil {
+				continue
+			}
+
+			var n ArticleNotification
+			if err := json.Unmarshal([]byte(notification.Extra), &n); err != nil {
+				slog.Warn("ignoring malformed article notification", "payload", notification.Extra, "error", err)
+				continue
+			}
+			handle(n)
+		case <-time.After(90 * time.Second):
+			// Make sure the connection is still alive when no notifications arrive.
+			go listener.Ping()
+		}
+	}
+}
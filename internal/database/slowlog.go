@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/logging"
+)
+
+// Tx is a transaction whose statements are checked against the slow query
+// threshold like those of its DB.
+type Tx struct {
+	*sql.Tx
+	db *DB
+}
+
+// SetSlowQueryThreshold makes statements taking longer than threshold be
+// logged with their redacted arguments. Zero, the default, disables it. It
+// must be called before the DB is used.
+func (db *DB) SetSlowQueryThreshold(threshold time.Duration) {
+	db.slowQueryThreshold = threshold
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer db.logSlowQuery(ctx, time.Now(), query, args)
+	return db.DB.QueryContext(ctx, query, args...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer db.logSlowQuery(ctx, time.Now(), query, args)
+	return db.DB.QueryRowContext(ctx, query, args...)
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer db.logSlowQuery(ctx, time.Now(), query, args)
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, db: db}, nil
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer tx.db.logSlowQuery(ctx, time.Now(), query, args)
+	return tx.Tx.QueryContext(ctx, query, args...)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer tx.db.logSlowQuery(ctx, time.Now(), query, args)
+	return tx.Tx.QueryRowContext(ctx, query, args...)
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer tx.db.logSlowQuery(ctx, time.Now(), query, args)
+	return tx.Tx.ExecContext(ctx, query, args...)
+}
+
+// logSlowQuery logs the statement started at start if it exceeded the
+// threshold. The statement is put on a single line, so that it can be pasted
+// after EXPLAIN ANALYZE with the arguments filled in. Queries are timed until
+// the driver received their first results, so the time spent streaming the
+// remaining rows is not counted.
+func (db *DB) logSlowQuery(ctx context.Context, start time.Time, query string, args []interface{}) {
+	elapsed := time.Since(start)
+	if db.slowQueryThreshold <= 0 || elapsed < db.slowQueryThreshold {
+		return
+	}
+
+	redacted := make([]interface{}, len(args))
+	for i, arg := range args {
+		redacted[i] = logging.Redact(arg)
+	}
+	logging.FromContext(ctx).LogAttrs(ctx, slog.LevelWarn, "slow query",
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+		slog.String("sql", strings.Join(strings.Fields(query), " ")),
+		slog.Any("args", redacted),
+	)
+}
@@ -0,0 +1,32 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/models"
+)
+
+// requireRole returns the caller when they have at least role.
+func requireRole(ctx context.Context, role auth.Role) (*auth.Principal, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, apperr.Unauthenticated("authentication required")
+	}
+	if !principal.HasRole(role) {
+		return nil, apperr.Forbidden("requires the " + string(role) + " role")
+	}
+	return principal, nil
+}
+
+// canEditArticle reports whether the principal may change or delete article:
+// editors and admins may edit any article, authors only those they created,
+// as told by their subject. Display names prove nothing, as callers pick them.
+func canEditArticle(principal *auth.Principal, article *models.Article) bool {
+	if principal.HasRole(auth.RoleEditor) {
+		return true
+	}
+	return principal.HasRole(auth.RoleAuthor) && article.Author != nil &&
+		article.Author.Subject != nil && *article.Author.Subject == principal.Subject
+}
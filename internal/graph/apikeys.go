@@ -0,0 +1,119 @@
+package graph
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/models"
+	"github.com/graphql-go/graphql"
+)
+
+func (r *Resolver) GetAPIKeys(p graphql.ResolveParams) (interface{}, error) {
+	if _, err := requireRole(p.Context, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	keys, err := r.db.ListAPIKeys(p.Context)
+	if err != nil {
+		return nil, queryError(p.Context, err)
+	}
+
+	result := make([]map[string]interface{}, len(keys))
+	for i, key := range keys {
+		result[i] = apiKeyToMap(key)
+	}
+	return result, nil
+}
+
+func (r *Resolver) CreateAPIKey(p graphql.ResolveParams) (interface{}, error) {
+	apiKey, key, err := r.createAPIKey(p)
+	return mutationPayloadFields(map[string]interface{}{
+		"apiKey": apiKey,
+		"key":    key,
+	}, err)
+}
+
+// createAPIKey issues a key and returns it in clear along with its metadata.
+// The key cannot be retrieved again afterwards.
+func (r *Resolver) createAPIKey(p graphql.ResolveParams) (interface{}, interface{}, error) {
+	if _, err := requireRole(p.Context, auth.RoleAdmin); err != nil {
+		return nil, nil, err
+	}
+
+	input := p.Args["input"].(map[string]interface{})
+	name := strings.TrimSpace(input["name"].(string))
+
+	var scopes []string
+	for _, scope := range input["scopes"].([]interface{}) {
+		scopes = append(scopes, scope.(string))
+	}
+
+	// Validate input
+	var invalid apperr.Errors
+	if name == "" {
+		invalid = append(invalid, apperr.BadUserInput("name cannot be empty", "input", "name"))
+	} else if len(name) > 255 {
+		invalid = append(invalid, apperr.BadUserInput("name must be at most 255 characters", "input", "name"))
+	}
+	if len(scopes) == 0 {
+		invalid = append(invalid, apperr.BadUserInput("at least one scope is required", "input", "scopes"))
+	}
+	if len(invalid) > 0 {
+		return nil, nil, invalid
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, nil, apperr.Internal(err)
+	}
+
+	apiKey, err := r.db.CreateAPIKey(p.Context, name, prefix, hash, scopes)
+	if err != nil {
+		return nil, nil, queryError(p.Context, err)
+	}
+
+	return apiKeyToMap(apiKey), key, nil
+}
+
+func (r *Resolver) RevokeAPIKey(p graphql.ResolveParams) (interface{}, error) {
+	apiKey, err := r.revokeAPIKey(p)
+	return mutationPayload("apiKey", apiKey, err)
+}
+
+func (r *Resolver) revokeAPIKey(p graphql.ResolveParams) (interface{}, error) {
+	if _, err := requireRole(p.Context, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(p.Args["id"], "id")
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, err := r.db.RevokeAPIKey(p.Context, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperr.NotFound(fmt.Sprintf("API key %d not found", id))
+	}
+	if err != nil {
+		return nil, queryError(p.Context, err)
+	}
+
+	return apiKeyToMap(apiKey), nil
+}
+
+func apiKeyToMap(key *models.APIKey) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         strconv.Itoa(key.ID),
+		"name":       key.Name,
+		"prefix":     key.Prefix,
+		"scopes":     key.Scopes,
+		"createdAt":  key.CreatedAt,
+		"lastUsedAt": key.LastUsedAt,
+		"revokedAt":  key.RevokedAt,
+	}
+}
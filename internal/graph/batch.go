@@ -0,0 +1,144 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/logging"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// Batch executes JSON POST requests whose body is an array of GraphQL
+// requests, answering with the array of their responses in the same order.
+// Each operation goes through next as a request of its own, so that it is
+// rate limited, checked and cached like a single one. Up to concurrency
+// operations run at a time; with more than one, mutations of a batch run in
+// no particular order. Batches of more than maxSize operations are rejected,
+// and a maxSize of 0 disables batching.
+func Batch(maxSize, concurrency int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if maxSize == 0 || r.Method != http.MethodPost || mediaType != "application/json" || r.Body == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				apperr.WriteHTTP(w, http.StatusBadRequest, apperr.BadUserInput("failed to read request body"))
+				return
+			}
+			trimmed := bytes.TrimLeft(body, " \t\r\n")
+			if len(trimmed) == 0 || trimmed[0] != '[' {
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var operations []json.RawMessage
+			if err := json.Unmarshal(body, &operations); err != nil {
+				apperr.WriteHTTP(w, http.StatusBadRequest, apperr.BadUserInput("batch must be a JSON array of GraphQL requests"))
+				return
+			}
+			if len(operations) == 0 {
+				apperr.WriteHTTP(w, http.StatusBadRequest, apperr.BadUserInput("batch must not be empty"))
+				return
+			}
+			if len(operations) > maxSize {
+				apperr.WriteHTTP(w, http.StatusBadRequest,
+					apperr.BadUserInput(fmt.Sprintf("batch of %d operations exceeds the maximum of %d", len(operations), maxSize)))
+				return
+			}
+
+			responses := make([]*batchResponse, len(operations))
+			slots := make(chan struct{}, max(concurrency, 1))
+			var wg sync.WaitGroup
+			for i, operation := range operations {
+				responses[i] = &batchResponse{header: make(http.Header)}
+				slots <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer func() {
+						<-slots
+						wg.Done()
+					}()
+					next.ServeHTTP(responses[i], batchOperation(r, operation))
+				}()
+			}
+			wg.Wait()
+
+			results := make([]json.RawMessage, len(responses))
+			retryAfter := 0
+			for i, response := range responses {
+				var ok bool
+				if results[i], ok = response.result(); !ok {
+					logging.FromContext(r.Context()).Error("batched operation answered without a GraphQL response",
+						"index", i, "status", response.status)
+					results[i], _ = json.Marshal(map[string]interface{}{
+						"errors": []gqlerrors.FormattedError{apperr.Format(apperr.Internal(nil))},
+					})
+				}
+				if seconds, err := strconv.Atoi(response.header.Get("Retry-After")); err == nil {
+					retryAfter = max(retryAfter, seconds)
+				}
+			}
+			// Let clients back off when some operations were rate limited
+			if retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(results)
+		})
+	}
+}
+
+// batchOperation returns a copy of r carrying a single operation of its
+// batch.
+func batchOperation(r *http.Request, operation json.RawMessage) *http.Request {
+	req := r.Clone(r.Context())
+	req.Body = io.NopCloser(bytes.NewReader(operation))
+	req.ContentLength = int64(len(operation))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Del("Content-Length")
+	return req
+}
+
+// batchResponse records the response to one operation of a batch. Headers
+// are its own since operations may run concurrently.
+type batchResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *batchResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *batchResponse) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *batchResponse) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+// result returns the GraphQL response recorded, and whether there is one.
+func (r *batchResponse) result() (json.RawMessage, bool) {
+	body := bytes.TrimSpace(r.body.Bytes())
+	return body, len(body) > 0 && json.Valid(body)
+}
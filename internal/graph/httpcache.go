@@ -0,0 +1,154 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/logging"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/handler"
+)
+
+// CacheQueries makes the GET persisted queries of anonymous clients
+// cacheable by browsers and proxies. Responses get an ETag derived from the
+// request and the time returned by modifiedAt, when articles or authors last
+// changed. Until the next change, clients revalidating with If-None-Match get
+// 304 Not Modified without the query being executed. Cache-Control lets
+// caches reuse a response for maxAge, or makes them revalidate every time
+// when it is zero. Responses holding errors are not cacheable.
+//
+// Queries sent as documents are not cacheable, so that clients cannot fill
+// shared caches with arbitrary queries. Neither are those with variables
+// longer than maxCacheableVariables, to keep the hashing cheap.
+func CacheQueries(modifiedAt func(context.Context) (time.Time, error), maxAge time.Duration) func(http.Handler) http.Handler {
+	cacheControl := "public, no-cache"
+	if maxAge > 0 {
+		cacheControl = fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Responses depend on the caller, who may see author emails, so
+			// only those of anonymous clients are shared
+			w.Header().Add("Vary", "Authorization")
+			if _, authenticated := auth.FromContext(r.Context()); authenticated || r.Method != http.MethodGet || !isPersisted(r.Context()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(r.URL.Query().Get("variables")) > maxCacheableVariables {
+				next.ServeHTTP(w, r)
+				return
+			}
+			opts := handler.NewRequestOptions(r)
+			if operationType, err := OperationType(opts.Query, opts.OperationName); err != nil || operationType != ast.OperationTypeQuery {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			modified, err := modifiedAt(r.Context())
+			if err != nil {
+				logging.FromContext(r.Context()).Warn("response not cacheable", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			etag := queryETag(modified, opts)
+			if etagMatches(r.Header.Get("If-None-Match"), etag) {
+				w.Header().Set("ETag", etag)
+				w.Header().Set("Cache-Control", cacheControl)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			response := &bufferedResponse{ResponseWriter: w}
+			next.ServeHTTP(response, r)
+			if response.cacheable() {
+				w.Header().Set("ETag", etag)
+				w.Header().Set("Cache-Control", cacheControl)
+			}
+			response.send()
+		})
+	}
+}
+
+// maxCacheableVariables is the length of the longest variables parameter
+// whose response is cached.
+const maxCacheableVariables = 4096
+
+// queryETag identifies the response to a query as of modifiedAt. It is weak
+// since compression changes the bytes sent.
+func queryETag(modifiedAt time.Time, opts *handler.RequestOptions) string {
+	variables, _ := json.Marshal(opts.Variables)
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		modifiedAt.UTC().Format(time.RFC3339Nano),
+		opts.OperationName,
+		opts.Query,
+		string(variables),
+	}, "\x00")))
+	return `W/"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches compares etag to an If-None-Match header the weak way, as
+// required for GET requests. The * wildcard is not a match: it asks whether
+// any representation exists, not whether the client holds this one.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasErrors reports whether body is not a GraphQL response without errors,
+// e.g. the GraphiQL page.
+func hasErrors(body []byte) bool {
+	var result struct {
+		Errors []json.RawMessage `json:"errors"`
+	}
+	return json.Unmarshal(body, &result) != nil || len(result.Errors) > 0
+}
+
+// bufferedResponse holds back the response until its headers are final.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *bufferedResponse) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *bufferedResponse) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+// cacheable reports whether the response is a GraphQL result without errors.
+func (r *bufferedResponse) cacheable() bool {
+	return r.status == http.StatusOK && !hasErrors(r.body.Bytes())
+}
+
+// send writes the held back response.
+func (r *bufferedResponse) send() {
+	if r.status != 0 {
+		r.ResponseWriter.WriteHeader(r.status)
+	}
+	r.ResponseWriter.Write(r.body.Bytes())
+}
@@ -0,0 +1,236 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/database"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/logging"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/models"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/storage"
+	"github.com/graphql-go/graphql"
+)
+
+// imageExtensions are the accepted image types, as detected from their
+// content, and the extensions of their keys.
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// SetImageStore makes uploadImage store files in s.
+func (r *Resolver) SetImageStore(s storage.Store) {
+	r.images = s
+}
+
+func (r *Resolver) UploadImage(p graphql.ResolveParams) (interface{}, error) {
+	image, err := r.uploadImage(p)
+	return mutationPayload("image", image, err)
+}
+
+// uploadImage stores the uploaded file, then records it for the article. The
+// type of the file is detected from its content, whatever the client
+// claims.
+func (r *Resolver) uploadImage(p graphql.ResolveParams) (interface{}, error) {
+	principal, err := requireRole(p.Context, auth.RoleAuthor)
+	if err != nil {
+		return nil, err
+	}
+	if r.images == nil {
+		return nil, apperr.Internal(errors.New("no image store configured"))
+	}
+
+	articleID, err := parseID(p.Args["articleId"], "articleId")
+	if err != nil {
+		return nil, err
+	}
+	file, err := uploadedFile(p.Context, p.Args["file"], "file")
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		return nil, apperr.Internal(fmt.Errorf("failed to open upload: %w", err))
+	}
+	defer content.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, apperr.BadUserInput("the file is empty or unreadable", "file")
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	extension, ok := imageExtensions[contentType]
+	if !ok {
+		return nil, apperr.BadUserInput("the file must be a PNG, JPEG, GIF or WebP image", "file")
+	}
+
+	article, err := r.getArticle(p.Context, articleID)
+	if err != nil {
+		return nil, err
+	}
+	if !canEditArticle(principal, article) {
+		return nil, apperr.Forbidden("authors can only add images to their own articles")
+	}
+
+	key, err := newImageKey(extension)
+	if err != nil {
+		return nil, apperr.Internal(err)
+	}
+	if err := r.images.Put(p.Context, key, contentType, io.MultiReader(bytes.NewReader(head), content)); err != nil {
+		return nil, apperr.Internal(err)
+	}
+
+	image, err := r.db.CreateArticleImage(p.Context, articleID, key, contentType, file.Size)
+	if err != nil {
+		r.deleteImageFiles(p.Context, []string{key})
+		if database.IsForeignKeyViolation(err) {
+			return nil, apperr.NotFound(fmt.Sprintf("article %d not found", articleID))
+		}
+		return nil, queryError(p.Context, err)
+	}
+	r.articlesChanged(p.Context)
+
+	return r.imageToMap(image), nil
+}
+
+// articleImagesKey holds the *imageBatch of an article map.
+const articleImagesKey = "imageBatch"
+
+// imageBatch loads the images of a page of articles with a single query, the
+// first time the images of one of them are resolved.
+type imageBatch struct {
+	articleIDs []int
+	once       sync.Once
+	images     map[int][]*models.Image
+	err        error
+}
+
+func newImageBatch(articles []*models.Article) *imageBatch {
+	ids := make([]int, len(articles))
+	for i, article := range articles {
+		ids[i] = article.ID
+	}
+	return &imageBatch{articleIDs: ids}
+}
+
+func (b *imageBatch) load(ctx context.Context, db *database.DB) (map[int][]*models.Image, error) {
+	b.once.Do(func() {
+		b.images, b.err = db.ListArticleImages(ctx, b.articleIDs)
+	})
+	return b.images, b.err
+}
+
+// ResolveArticleImages returns the images of the article being resolved.
+// Articles listed together share an imageBatch; others are loaded alone.
+func (r *Resolver) ResolveArticleImages(p graphql.ResolveParams) (interface{}, error) {
+	article, _ := p.Source.(map[string]interface{})
+	id, err := strconv.Atoi(fmt.Sprint(article["id"]))
+	if err != nil {
+		return nil, apperr.Internal(fmt.Errorf("article without ID: %w", err))
+	}
+
+	batch, ok := article[articleImagesKey].(*imageBatch)
+	if !ok {
+		batch = &imageBatch{articleIDs: []int{id}}
+	}
+	images, err := batch.load(p.Context, r.db)
+	if err != nil {
+		return nil, queryError(p.Context, err)
+	}
+
+	result := make([]map[string]interface{}, len(images[id]))
+	for i, image := range images[id] {
+		result[i] = r.imageToMap(image)
+	}
+	return result, nil
+}
+
+// ImageAccess guards the image files served by next, which are named by
+// their key relative to where it is mounted. Images of published articles
+// are public; those of other articles are only served to the callers who may
+// edit them, and are not cached, as drafts are private. It has to run after
+// authentication.
+func (r *Resolver) ImageAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := strings.TrimPrefix(req.URL.Path, "/")
+		article, err := scanArticle(r.db.QueryRowContext(req.Context(), `
+            SELECT `+articleColumns+`
+            FROM article_images i
+            JOIN articles a ON i.article_id = a.id
+            JOIN authors au ON a.author_id = au.id
+            WHERE i.key = $1`, key))
+		if err == sql.ErrNoRows {
+			http.NotFound(w, req)
+			return
+		}
+		if err != nil {
+			logging.FromContext(req.Context()).Error("failed to look up image", "key", key, "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if article.Status != models.StatusPublished {
+			// Unpublished articles do not exist for other callers
+			principal, ok := auth.FromContext(req.Context())
+			if !ok || !canEditArticle(principal, article) {
+				http.NotFound(w, req)
+				return
+			}
+			w.Header().Set("Cache-Control", "private, no-store")
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// deleteImageFiles removes the files of images whose rows are gone. Failures
+// only leave unreferenced files behind, so they are logged.
+func (r *Resolver) deleteImageFiles(ctx context.Context, keys []string) {
+	if r.images == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := r.images.Delete(ctx, key); err != nil {
+			logging.FromContext(ctx).Warn("failed to delete image file", "key", key, "error", err)
+		}
+	}
+}
+
+func (r *Resolver) imageToMap(image *models.Image) map[string]interface{} {
+	url := ""
+	if r.images != nil {
+		url = r.images.URL(image.Key)
+	}
+	return map[string]interface{}{
+		"id":          strconv.Itoa(image.ID),
+		"url":         url,
+		"contentType": image.ContentType,
+		"size":        image.Size,
+		"createdAt":   image.CreatedAt,
+	}
+}
+
+// newImageKey returns a random key, so that image URLs cannot be guessed and
+// are never reused.
+func newImageKey(extension string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate image key: %w", err)
+	}
+	return hex.EncodeToString(b) + extension, nil
+}
@@ -0,0 +1,74 @@
+package graph
+
+import (
+	"net/http"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// introspectionFields are the meta fields describing the schema. __typename
+// only names the type of an object already returned, so it stays allowed.
+var introspectionFields = map[string]bool{
+	"__schema": true,
+	"__type":   true,
+}
+
+// CheckIntrospection returns a FORBIDDEN error when query selects __schema
+// or __type anywhere, for servers with introspection disabled. Documents
+// that do not parse are left for execution to report.
+func CheckIntrospection(query string) *apperr.Error {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil
+	}
+
+	for _, def := range doc.Definitions {
+		var selections *ast.SelectionSet
+		switch def := def.(type) {
+		case *ast.OperationDefinition:
+			selections = def.SelectionSet
+		case *ast.FragmentDefinition:
+			selections = def.SelectionSet
+		}
+		if selectsIntrospection(selections) {
+			return apperr.Forbidden("GraphQL introspection is disabled on this server")
+		}
+	}
+	return nil
+}
+
+// selectsIntrospection walks a selection set. Fragment spreads are skipped
+// since every fragment definition is checked on its own.
+func selectsIntrospection(set *ast.SelectionSet) bool {
+	if set == nil {
+		return false
+	}
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if introspectionFields[selection.Name.Value] || selectsIntrospection(selection.SelectionSet) {
+				return true
+			}
+		case *ast.InlineFragment:
+			if selectsIntrospection(selection.SelectionSet) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DisableIntrospection rejects HTTP requests for introspection queries with
+// status 403 before they reach next. WebSocketHandler.DisableIntrospection
+// does the same for operations sent over WebSockets.
+func DisableIntrospection(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := CheckIntrospection(PeekRequest(r).Query); err != nil {
+			apperr.WriteHTTP(w, http.StatusForbidden, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,67 @@
+package graph
+
+import (
+	"errors"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+)
+
+// userErrorCodes are the error codes a client can act on, e.g. by showing a
+// message next to a form field. They are reported in the userErrors of a
+// mutation payload instead of failing the whole operation.
+var userErrorCodes = map[apperr.Code]bool{
+	apperr.CodeBadUserInput: true,
+	apperr.CodeNotFound:     true,
+	apperr.CodeConflict:     true,
+}
+
+// mutationPayload builds the payload every mutation returns: the result under
+// field, plus the userErrors explaining why the result is null. Any other
+// error is returned as a regular GraphQL error.
+func mutationPayload(field string, result interface{}, err error) (interface{}, error) {
+	return mutationPayloadFields(map[string]interface{}{field: result}, err)
+}
+
+// mutationPayloadFields is mutationPayload for payloads with several result
+// fields, which are all null when there are userErrors.
+func mutationPayloadFields(fields map[string]interface{}, err error) (interface{}, error) {
+	userErrors := []map[string]interface{}{}
+
+	if err != nil {
+		var list apperr.Errors
+		var single *apperr.Error
+		switch {
+		case errors.As(err, &list):
+		case errors.As(err, &single):
+			list = apperr.Errors{single}
+		default:
+			return nil, err
+		}
+
+		for _, e := range list {
+			if !userErrorCodes[e.Code] {
+				return nil, e
+			}
+			var fieldPath interface{}
+			if len(e.Field) > 0 {
+				fieldPath = e.Field
+			}
+			userErrors = append(userErrors, map[string]interface{}{
+				"field":   fieldPath,
+				"message": e.Message,
+				"code":    string(e.Code),
+			})
+		}
+	}
+
+	payload := map[string]interface{}{
+		"userErrors": userErrors,
+	}
+	for field, result := range fields {
+		if err != nil {
+			result = nil
+		}
+		payload[field] = result
+	}
+	return payload, nil
+}
@@ -0,0 +1,101 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+)
+
+// PersistedQueries are the documents registered ahead of time by the
+// clients, by the hex SHA-256 hash of their text. Clients send the hash
+// instead of the document, in the persistedQuery extension of a GET request:
+//
+//	GET /graphql?extensions={"persistedQuery":{"version":1,"sha256Hash":"..."}}&variables=...
+//
+// Only the responses to persisted queries are cacheable by browsers and
+// proxies, see CacheQueries.
+type PersistedQueries map[string]string
+
+// LoadPersistedQueries reads a JSON object mapping hashes to documents. Each
+// hash has to match its document, so that a stale file is not served.
+func LoadPersistedQueries(path string) (PersistedQueries, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read persisted queries: %w", err)
+	}
+
+	var queries PersistedQueries
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return nil, fmt.Errorf("failed to parse persisted queries: %w", err)
+	}
+	for hash, query := range queries {
+		if hash != persistedQueryHash(query) {
+			return nil, fmt.Errorf("persisted query %s does not match the hash of its document", hash)
+		}
+	}
+	return queries, nil
+}
+
+func persistedQueryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// persistedKey marks requests whose document is a persisted query.
+type persistedKey struct{}
+
+// isPersisted reports whether the document of the request was looked up by
+// PersistedQueries.Middleware.
+func isPersisted(ctx context.Context) bool {
+	persisted, _ := ctx.Value(persistedKey{}).(bool)
+	return persisted
+}
+
+// Middleware replaces the hash of GET requests for a persisted query by its
+// document. Unknown hashes are rejected with a NOT_FOUND error. It has to
+// run before the middlewares looking at the document, such as rate limiting.
+func (q PersistedQueries) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		if r.Method != http.MethodGet || params.Get("extensions") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var extensions struct {
+			PersistedQuery *struct {
+				Version    int    `json:"version"`
+				SHA256Hash string `json:"sha256Hash"`
+			} `json:"persistedQuery"`
+		}
+		if err := json.Unmarshal([]byte(params.Get("extensions")), &extensions); err != nil {
+			apperr.WriteHTTP(w, http.StatusBadRequest, apperr.BadUserInput("extensions must be a JSON object", "extensions"))
+			return
+		}
+		if extensions.PersistedQuery == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if extensions.PersistedQuery.Version != 1 {
+			apperr.WriteHTTP(w, http.StatusBadRequest, apperr.BadUserInput("unsupported persisted query version", "extensions"))
+			return
+		}
+		query, ok := q[extensions.PersistedQuery.SHA256Hash]
+		if !ok {
+			apperr.WriteHTTP(w, http.StatusBadRequest, apperr.NotFound("persisted query not found"))
+			return
+		}
+
+		params.Del("extensions")
+		params.Set("query", query)
+		req := r.Clone(context.WithValue(r.Context(), persistedKey{}, true))
+		req.URL.RawQuery = params.Encode()
+		next.ServeHTTP(w, req)
+	})
+}
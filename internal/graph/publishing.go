@@ -0,0 +1,161 @@
+package graph
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/database"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/models"
+	"github.com/graphql-go/graphql"
+)
+
+func (r *Resolver) PublishArticle(p graphql.ResolveParams) (interface{}, error) {
+	article, err := r.publishArticle(p)
+	return mutationPayload("article", article, err)
+}
+
+// publishArticle makes an article public and announces it to subscribers.
+// Editors publish any article, publishers only their own. Publishing an
+// article that already is keeps its publication time.
+func (r *Resolver) publishArticle(p graphql.ResolveParams) (interface{}, error) {
+	principal, err := requireRole(p.Context, auth.RolePublisher)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(p.Args["id"], "id")
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTx(p.Context, nil)
+	if err != nil {
+		return nil, queryError(p.Context, fmt.Errorf("failed to begin transaction: %w", err))
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(p.Context, `
+        UPDATE articles a SET status = 'PUBLISHED', published_at = NOW()
+        FROM authors au
+        WHERE a.id = $1 AND au.id = a.author_id AND a.status <> 'PUBLISHED'
+          AND ($2 OR au.subject = $3)`,
+		id, principal.HasRole(auth.RoleEditor), principal.Subject)
+	if err != nil {
+		return nil, queryError(p.Context, fmt.Errorf("failed to publish article: %w", err))
+	}
+	if published, _ := result.RowsAffected(); published == 0 {
+		article, err := r.getArticle(p.Context, id)
+		if err != nil {
+			return nil, err
+		}
+		if !canEditArticle(principal, article) {
+			return nil, apperr.Forbidden("publishers can only publish their own articles")
+		}
+		return articleToMap(article), nil
+	}
+
+	// Let other server instances know about the article once we commit
+	err = database.NotifyArticleCreated(p.Context, tx, database.ArticleNotification{
+		ID:     id,
+		Origin: r.broker.InstanceID(),
+	})
+	if err != nil {
+		return nil, queryError(p.Context, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, queryError(p.Context, fmt.Errorf("failed to commit transaction: %w", err))
+	}
+	r.articlesChanged(p.Context)
+
+	article, err := r.getArticle(p.Context, id)
+	if err != nil {
+		return nil, err
+	}
+	r.broker.Publish(article)
+
+	return articleToMap(article), nil
+}
+
+func (r *Resolver) SubmitArticle(p graphql.ResolveParams) (interface{}, error) {
+	article, err := r.submitArticle(p)
+	return mutationPayload("article", article, err)
+}
+
+// submitArticle asks the editors to review a draft. Authors submit their own
+// drafts; submitting an article that is already in review changes nothing.
+func (r *Resolver) submitArticle(p graphql.ResolveParams) (interface{}, error) {
+	principal, err := requireRole(p.Context, auth.RoleAuthor)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(p.Args["id"], "id")
+	if err != nil {
+		return nil, err
+	}
+
+	article, err := scanArticle(r.db.QueryRowContext(p.Context, `
+        UPDATE articles a SET status = 'IN_REVIEW'
+        FROM authors au
+        WHERE a.id = $1 AND au.id = a.author_id AND a.status = 'DRAFT'
+          AND ($2 OR au.subject = $3)
+        RETURNING `+articleColumns,
+		id, principal.HasRole(auth.RoleEditor), principal.Subject))
+	if err == sql.ErrNoRows {
+		// Tell why no article was submitted
+		current, err := r.getArticle(p.Context, id)
+		if err != nil {
+			return nil, err
+		}
+		if !canEditArticle(principal, current) {
+			return nil, apperr.Forbidden("authors can only submit their own articles")
+		}
+		if current.Status != models.StatusInReview {
+			return nil, apperr.BadUserInput("only drafts can be submitted for review", "id")
+		}
+		return articleToMap(current), nil
+	}
+	if err != nil {
+		return nil, queryError(p.Context, fmt.Errorf("failed to submit article: %w", err))
+	}
+	r.articlesChanged(p.Context)
+
+	return articleToMap(article), nil
+}
+
+func (r *Resolver) UnpublishArticle(p graphql.ResolveParams) (interface{}, error) {
+	article, err := r.unpublishArticle(p)
+	return mutationPayload("article", article, err)
+}
+
+// unpublishArticle turns an article back into a draft, hiding it from
+// everyone but its author and editors.
+func (r *Resolver) unpublishArticle(p graphql.ResolveParams) (interface{}, error) {
+	if _, err := requireRole(p.Context, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(p.Args["id"], "id")
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.ExecContext(p.Context, `
+        UPDATE articles SET status = 'DRAFT', published_at = NULL
+        WHERE id = $1 AND status = 'PUBLISHED'`, id)
+	if err != nil {
+		return nil, queryError(p.Context, fmt.Errorf("failed to unpublish article: %w", err))
+	}
+	if unpublished, _ := result.RowsAffected(); unpublished > 0 {
+		r.articlesChanged(p.Context)
+	}
+
+	article, err := r.getArticle(p.Context, id)
+	if err != nil {
+		return nil, err
+	}
+	return articleToMap(article), nil
+}
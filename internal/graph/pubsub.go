@@ -0,0 +1,74 @@
+package graph
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/models"
+)
+
+// subscriberBuffer is how many articles may queue up for a slow subscriber
+// before further articles are dropped for it.
+const subscriberBuffer = 16
+
+// ArticleBroker fans out newly created articles to active subscriptions
+// within this server instance.
+type ArticleBroker struct {
+	instanceID string
+
+	mu          sync.RWMutex
+	subscribers map[chan *models.Article]struct{}
+}
+
+func NewArticleBroker() *ArticleBroker {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	return &ArticleBroker{
+		instanceID:  hex.EncodeToString(id),
+		subscribers: make(map[chan *models.Article]struct{}),
+	}
+}
+
+// InstanceID identifies this broker in notifications shared with other instances,
+// so that an instance can ignore the articles it already published itself.
+func (b *ArticleBroker) InstanceID() string {
+	return b.instanceID
+}
+
+// Subscribe returns a channel receiving every published article. The channel is
+// closed once ctx is done.
+func (b *ArticleBroker) Subscribe(ctx context.Context) <-chan *models.Article {
+	ch := make(chan *models.Article, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		close(ch)
+		b.mu.Unlock()
+	}()
+
+	return ch
+}
+
+// Publish delivers article to all current subscribers without blocking.
+func (b *ArticleBroker) Publish(article *models.Article) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- article:
+		default:
+			// Subscriber is not keeping up; drop rather than stall the publisher.
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/cache"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/logging"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/printer"
+)
+
+// cacheableFields are the root fields whose results QueryCache keeps. API
+// keys are left out since their last use changes with every request.
+var cacheableFields = map[string]bool{
+	"articles":   true,
+	"__typename": true,
+}
+
+// QueryCache keeps the responses to article listings, so that frequent
+// queries such as the one of the homepage skip the database. The resolvers
+// drop every entry when they change articles or authors. Entries also expire
+// after a TTL, which bounds how long updates and deletions made through other
+// server instances go unnoticed.
+type QueryCache struct {
+	store cache.Store
+	ttl   time.Duration
+	// generation changes with every invalidation, so that responses computed
+	// from data read before it are not stored after it.
+	generation atomic.Uint64
+}
+
+func NewQueryCache(store cache.Store, ttl time.Duration) *QueryCache {
+	return &QueryCache{store: store, ttl: ttl}
+}
+
+// Invalidate drops every cached response.
+func (c *QueryCache) Invalidate(ctx context.Context) {
+	c.generation.Add(1)
+	if err := c.store.Purge(ctx); err != nil {
+		logging.FromContext(ctx).Error("failed to purge query cache", "error", err)
+	}
+}
+
+// Middleware answers cacheable queries from the cache, and stores the
+// responses to those without errors. The X-Cache response header tells
+// whether a cacheable query was a HIT or a MISS. If the store fails, queries
+// are executed as if they were not cached.
+func (c *QueryCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Browsers asking for GraphiQL get the page rather than a result
+		if r.Method != http.MethodGet && r.Method != http.MethodPost ||
+			r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key, ok := queryCacheKey(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger := logging.FromContext(r.Context())
+		body, hit, err := c.store.Get(r.Context(), key)
+		if err != nil {
+			logger.Warn("query cache lookup failed", "error", err)
+		}
+		if hit {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set("X-Cache", "HIT")
+			w.Write(body)
+			return
+		}
+
+		generation := c.generation.Load()
+		response := &bufferedResponse{ResponseWriter: w}
+		next.ServeHTTP(response, r)
+		if response.cacheable() && c.generation.Load() == generation {
+			if err := c.store.Set(r.Context(), key, response.body.Bytes(), c.ttl); err != nil {
+				logger.Warn("failed to store query result", "error", err)
+			}
+		}
+		w.Header().Set("X-Cache", "MISS")
+		response.send()
+	})
+}
+
+// queryCacheKey identifies the response to r by its normalized document,
+// operation name and variables, and the roles of the caller, which decide
+// whether author emails are visible. It reports false for requests that are
+// not cacheable.
+func queryCacheKey(r *http.Request) (string, bool) {
+	opts := PeekRequest(r)
+	doc, err := parser.Parse(parser.ParseParams{Source: opts.Query})
+	if err != nil {
+		return "", false
+	}
+	op, err := selectOperation(doc, opts.OperationName)
+	if err != nil || op.Operation != ast.OperationTypeQuery {
+		return "", false
+	}
+	for _, selection := range op.SelectionSet.Selections {
+		field, ok := selection.(*ast.Field)
+		if !ok || !cacheableFields[field.Name.Value] {
+			return "", false
+		}
+	}
+
+	viewer := "anonymous"
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		roles := make([]string, len(principal.Roles))
+		for i, role := range principal.Roles {
+			roles[i] = string(role)
+		}
+		sort.Strings(roles)
+		viewer = strings.Join(roles, ",")
+		// Authors also see their own unpublished articles
+		if principal.HasRole(auth.RoleAuthor) && !principal.HasRole(auth.RoleEditor) {
+			viewer += "\x00" + principal.Subject
+		}
+	}
+
+	variables, err := json.Marshal(opts.Variables)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		fmt.Sprint(printer.Print(doc)),
+		opts.OperationName,
+		string(variables),
+		viewer,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:]), true
+}
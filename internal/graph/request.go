@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/graphql-go/handler"
+)
+
+// LimitRequestBody rejects requests whose body is larger than maxBytes with
+// status 413. It has to run before the middlewares peeking at the operations,
+// which each buffer the body.
+func LimitRequestBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				apperr.WriteHTTP(w, http.StatusRequestEntityTooLarge, apperr.BadUserInput("request body is too large"))
+				return
+			}
+			if err != nil {
+				apperr.WriteHTTP(w, http.StatusBadRequest, apperr.BadUserInput("failed to read request body"))
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PeekRequest returns the GraphQL parameters of r, parsed like the GraphQL
+// handler will, without consuming the body. Middlewares use it to tell
+// queries from mutations before the request is executed.
+func PeekRequest(r *http.Request) *handler.RequestOptions {
+	if r.Body == nil || r.Body == http.NoBody {
+		return handler.NewRequestOptions(r)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return &handler.RequestOptions{}
+	}
+
+	peek := r.Clone(r.Context())
+	peek.Body = io.NopCloser(bytes.NewReader(body))
+	return handler.NewRequestOptions(peek)
+}
+
+// RequestOperationType returns the type of the operation r would execute, or
+// "" when the request is not a valid GraphQL request.
+func RequestOperationType(r *http.Request) string {
+	opts := PeekRequest(r)
+	operationType, err := OperationType(opts.Query, opts.OperationName)
+	if err != nil {
+		return ""
+	}
+	return operationType
+}
@@ -1,101 +1,374 @@
 package graph
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/mail"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
 	"github.com/StillLearnSVN/go-graphql-articles/internal/database"
 	"github.com/StillLearnSVN/go-graphql-articles/internal/models"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/storage"
 	"github.com/graphql-go/graphql"
 )
 
 type Resolver struct {
-	db *database.DB
+	db         *database.DB
+	cfg        config.GraphQL
+	broker     *ArticleBroker
+	queryCache *QueryCache
+	images     storage.Store
 }
 
-func NewResolver(db *database.DB) *Resolver {
-	return &Resolver{db: db}
+func NewResolver(db *database.DB, cfg config.GraphQL) *Resolver {
+	return &Resolver{db: db, cfg: cfg, broker: NewArticleBroker()}
+}
+
+// Broker returns the broker feeding articleCreated subscriptions, which
+// announce articles as they are published.
+func (r *Resolver) Broker() *ArticleBroker {
+	return r.broker
+}
+
+// SetQueryCache makes the resolvers invalidate c whenever they change
+// articles or authors, or learn that another instance published an article.
+func (r *Resolver) SetQueryCache(c *QueryCache) {
+	r.queryCache = c
+}
+
+func (r *Resolver) articlesChanged(ctx context.Context) {
+	if r.queryCache != nil {
+		r.queryCache.Invalidate(ctx)
+	}
 }
 
 func (r *Resolver) CreateArticle(p graphql.ResolveParams) (interface{}, error) {
+	article, err := r.createArticle(p)
+	return mutationPayload("article", article, err)
+}
+
+func (r *Resolver) createArticle(p graphql.ResolveParams) (interface{}, error) {
+	// The author is always the authenticated caller
+	principal, err := requireRole(p.Context, auth.RoleAuthor)
+	if err != nil {
+		return nil, err
+	}
+
 	input := p.Args["input"].(map[string]interface{})
 
 	title := input["title"].(string)
 	body := input["body"].(string)
-	authorName := input["authorName"].(string)
 
 	// Validate input
+	var invalid apperr.Errors
 	if strings.TrimSpace(title) == "" {
-		return nil, fmt.Errorf("title cannot be empty")
+		invalid = append(invalid, apperr.BadUserInput("title cannot be empty", "input", "title"))
 	}
 	if strings.TrimSpace(body) == "" {
-		return nil, fmt.Errorf("body cannot be empty")
+		invalid = append(invalid, apperr.BadUserInput("body cannot be empty", "input", "body"))
 	}
-	if strings.TrimSpace(authorName) == "" {
-		return nil, fmt.Errorf("author name cannot be empty")
+	if len(invalid) > 0 {
+		return nil, invalid
 	}
 
 	// Begin transaction for data consistency
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(p.Context, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, queryError(p.Context, fmt.Errorf("failed to begin transaction: %w", err))
 	}
 	defer tx.Rollback()
 
-	// Insert or get author
+	// Insert or get the caller's author, keeping the email up to date with the
+	// token. Authors are keyed on the subject; names may repeat.
 	var authorID int
-	err = tx.QueryRow(`
-        INSERT INTO authors (name) VALUES ($1) 
-        ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name 
-        RETURNING id`, authorName).Scan(&authorID)
+	err = tx.QueryRowContext(p.Context, `
+        INSERT INTO authors (subject, name, email) VALUES ($1, $2, NULLIF($3, '')) 
+        ON CONFLICT (subject) DO UPDATE SET email = COALESCE(EXCLUDED.email, authors.email) 
+        RETURNING id`, principal.Subject, principal.Name, principal.Email).Scan(&authorID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert/get author: %w", err)
+		return nil, queryError(p.Context, fmt.Errorf("failed to insert/get author: %w", err))
 	}
 
-	// Insert article
+	// Insert article, as a draft until an editor publishes it
 	var article models.Article
-	err = tx.QueryRow(`
+	err = tx.QueryRowContext(p.Context, `
         INSERT INTO articles (title, body, author_id) 
         VALUES ($1, $2, $3) 
-        RETURNING id, title, body, author_id, created_at`,
+        RETURNING id, title, body, author_id, created_at, status, published_at`,
 		title, body, authorID).Scan(
 		&article.ID, &article.Title, &article.Body,
-		&article.AuthorID, &article.CreatedAt)
+		&article.AuthorID, &article.CreatedAt,
+		&article.Status, &article.PublishedAt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert article: %w", err)
+		return nil, queryError(p.Context, fmt.Errorf("failed to insert article: %w", err))
 	}
 
 	// Get author details
 	var author models.Author
-	err = tx.QueryRow("SELECT id, name FROM authors WHERE id = $1", authorID).Scan(&author.ID, &author.Name)
+	err = tx.QueryRowContext(p.Context, "SELECT id, name, email, subject FROM authors WHERE id = $1", authorID).Scan(&author.ID, &author.Name, &author.Email, &author.Subject)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get author: %w", err)
+		return nil, queryError(p.Context, fmt.Errorf("failed to get author: %w", err))
 	}
 
 	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, queryError(p.Context, fmt.Errorf("failed to commit transaction: %w", err))
 	}
 
-	// article.Author = &author
+	article.Author = &author
+	r.articlesChanged(p.Context)
 
-	return map[string]interface{}{
-		"id":        strconv.Itoa(article.ID),
-		"title":     article.Title,
-		"body":      article.Body,
-		"author":    author,
-		"createdAt": article.CreatedAt.Format(time.RFC3339),
-	}, nil
+	return articleToMap(&article), nil
+}
+
+func (r *Resolver) UpdateArticle(p graphql.ResolveParams) (interface{}, error) {
+	article, err := r.updateArticle(p)
+	return mutationPayload("article", article, err)
+}
+
+func (r *Resolver) updateArticle(p graphql.ResolveParams) (interface{}, error) {
+	principal, err := requireRole(p.Context, auth.RoleAuthor)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(p.Args["id"], "id")
+	if err != nil {
+		return nil, err
+	}
+
+	input := p.Args["input"].(map[string]interface{})
+	title, hasTitle := input["title"].(string)
+	body, hasBody := input["body"].(string)
+	status, hasStatus := input["status"].(string)
+
+	// Validate input
+	var invalid apperr.Errors
+	if hasTitle && strings.TrimSpace(title) == "" {
+		invalid = append(invalid, apperr.BadUserInput("title cannot be empty", "input", "title"))
+	}
+	if hasBody && strings.TrimSpace(body) == "" {
+		invalid = append(invalid, apperr.BadUserInput("body cannot be empty", "input", "body"))
+	}
+	if hasStatus && status == models.StatusPublished {
+		invalid = append(invalid, apperr.BadUserInput("articles are published with publishArticle", "input", "status"))
+	}
+	if len(invalid) > 0 {
+		return nil, invalid
+	}
+
+	// Only overwrite the fields present in the input. The UPDATE itself checks
+	// that the caller owns the article, or is an editor, and that only editors
+	// take published articles back, so that the checks cannot race with other
+	// changes to the article.
+	isEditor := principal.HasRole(auth.RoleEditor)
+	article, err := scanArticle(r.db.QueryRowContext(p.Context, `
+        UPDATE articles a SET
+            title = CASE WHEN $2 THEN $3 ELSE a.title END,
+            body = CASE WHEN $4 THEN $5 ELSE a.body END,
+            status = CASE WHEN $6 THEN $7 ELSE a.status END,
+            published_at = CASE WHEN $6 THEN NULL ELSE a.published_at END
+        FROM authors au
+        WHERE a.id = $1 AND au.id = a.author_id
+          AND ($8 OR au.subject = $9)
+          AND ($8 OR NOT $6 OR a.status <> 'PUBLISHED')
+        RETURNING `+articleColumns,
+		id, hasTitle, title, hasBody, body, hasStatus, status, isEditor, principal.Subject))
+	if err == sql.ErrNoRows {
+		// Tell why no article was updated
+		current, err := r.getArticle(p.Context, id)
+		if err != nil {
+			return nil, err
+		}
+		if !canEditArticle(principal, current) {
+			return nil, apperr.Forbidden("authors can only edit their own articles")
+		}
+		return nil, apperr.Forbidden("only editors can unpublish articles")
+	}
+	if err != nil {
+		return nil, queryError(p.Context, fmt.Errorf("failed to update article: %w", err))
+	}
+	r.articlesChanged(p.Context)
+
+	return articleToMap(article), nil
+}
+
+func (r *Resolver) DeleteArticle(p graphql.ResolveParams) (interface{}, error) {
+	id, err := r.deleteArticle(p)
+	return mutationPayload("deletedArticleId", id, err)
+}
+
+func (r *Resolver) deleteArticle(p graphql.ResolveParams) (interface{}, error) {
+	principal, err := requireRole(p.Context, auth.RoleAuthor)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(p.Args["id"], "id")
+	if err != nil {
+		return nil, err
+	}
+
+	// The DELETE only matches articles the caller may delete. Image rows are
+	// deleted along with the article; the outer query still sees them, since
+	// it reads the snapshot taken before the statement, so their files can be
+	// deleted afterwards.
+	rows, err := r.db.QueryContext(p.Context, `
+        WITH deleted AS (
+            DELETE FROM articles a USING authors au
+            WHERE a.id = $1 AND au.id = a.author_id AND ($2 OR au.subject = $3)
+            RETURNING a.id
+        )
+        SELECT i.key FROM deleted LEFT JOIN article_images i ON i.article_id = deleted.id`,
+		id, principal.HasRole(auth.RoleEditor), principal.Subject)
+	if err != nil {
+		return nil, queryError(p.Context, fmt.Errorf("failed to delete article: %w", err))
+	}
+	defer rows.Close()
+
+	deleted := false
+	var keys []string
+	for rows.Next() {
+		var key sql.NullString
+		if err := rows.Scan(&key); err != nil {
+			return nil, queryError(p.Context, fmt.Errorf("failed to delete article: %w", err))
+		}
+		deleted = true
+		if key.Valid {
+			keys = append(keys, key.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(p.Context, fmt.Errorf("failed to delete article: %w", err))
+	}
+
+	if !deleted {
+		// Tell why no article was deleted
+		article, err := r.getArticle(p.Context, id)
+		if err != nil {
+			return nil, err
+		}
+		if !canEditArticle(principal, article) {
+			return nil, apperr.Forbidden("authors can only delete their own articles")
+		}
+		return nil, apperr.NotFound(fmt.Sprintf("article %d not found", id))
+	}
+	r.articlesChanged(p.Context)
+	r.deleteImageFiles(p.Context, keys)
+
+	return strconv.Itoa(id), nil
+}
+
+func (r *Resolver) UpdateAuthor(p graphql.ResolveParams) (interface{}, error) {
+	author, err := r.updateAuthor(p)
+	return mutationPayload("author", author, err)
+}
+
+func (r *Resolver) updateAuthor(p graphql.ResolveParams) (interface{}, error) {
+	if _, err := requireRole(p.Context, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(p.Args["id"], "id")
+	if err != nil {
+		return nil, err
+	}
+
+	input := p.Args["input"].(map[string]interface{})
+	name, hasName := input["name"].(string)
+	email, hasEmail := input["email"].(string)
+
+	// Validate input
+	var invalid apperr.Errors
+	if hasName && strings.TrimSpace(name) == "" {
+		invalid = append(invalid, apperr.BadUserInput("name cannot be empty", "input", "name"))
+	}
+	if hasEmail && email != "" {
+		if _, err := mail.ParseAddress(email); err != nil {
+			invalid = append(invalid, apperr.BadUserInput("email is not a valid address", "input", "email"))
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, invalid
+	}
+
+	// Only overwrite the fields present in the input; an empty email clears it
+	var author models.Author
+	err = r.db.QueryRowContext(p.Context, `
+        UPDATE authors SET
+            name = CASE WHEN $2 THEN $3 ELSE name END,
+            email = CASE WHEN $4 THEN NULLIF($5, '') ELSE email END
+        WHERE id = $1
+        RETURNING id, name, email`,
+		id, hasName, name, hasEmail, email).Scan(&author.ID, &author.Name, &author.Email)
+	if err == sql.ErrNoRows {
+		return nil, apperr.NotFound(fmt.Sprintf("author %d not found", id))
+	}
+	if err != nil {
+		return nil, queryError(p.Context, fmt.Errorf("failed to update author: %w", err))
+	}
+	r.articlesChanged(p.Context)
+
+	return authorToMap(&author), nil
+}
+
+func (r *Resolver) DeleteAuthor(p graphql.ResolveParams) (interface{}, error) {
+	id, err := r.deleteAuthor(p)
+	return mutationPayload("deletedAuthorId", id, err)
+}
+
+func (r *Resolver) deleteAuthor(p graphql.ResolveParams) (interface{}, error) {
+	if _, err := requireRole(p.Context, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(p.Args["id"], "id")
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.ExecContext(p.Context, "DELETE FROM authors WHERE id = $1", id)
+	if database.IsForeignKeyViolation(err) {
+		return nil, apperr.Conflict("author still has articles", "id")
+	}
+	if err != nil {
+		return nil, queryError(p.Context, fmt.Errorf("failed to delete author: %w", err))
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return nil, apperr.NotFound(fmt.Sprintf("author %d not found", id))
+	}
+	r.articlesChanged(p.Context)
+
+	return strconv.Itoa(id), nil
+}
+
+// ResolveAuthorEmail only reveals author emails to editors and admins.
+func (r *Resolver) ResolveAuthorEmail(p graphql.ResolveParams) (interface{}, error) {
+	if _, err := requireRole(p.Context, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	author, _ := p.Source.(map[string]interface{})
+	if email, ok := author["email"].(*string); ok && email != nil {
+		return *email, nil
+	}
+	return nil, nil
 }
 
 func (r *Resolver) GetArticles(p graphql.ResolveParams) (interface{}, error) {
 	// Parse pagination parameters
-	first := 10 // default
+	first := r.cfg.DefaultPageSize
 	if f, ok := p.Args["first"].(int); ok && f > 0 {
 		first = f
-		if first > 100 { // Limit to prevent abuse
-			first = 100
+		if first > r.cfg.MaxPageSize { // Limit to prevent abuse
+			first = r.cfg.MaxPageSize
 		}
 	}
 
@@ -114,6 +387,11 @@ func (r *Resolver) GetArticles(p graphql.ResolveParams) (interface{}, error) {
 		authorFilter = strings.TrimSpace(a)
 	}
 
+	statusFilter, hasStatusFilter := p.Args["status"].(string)
+
+	createdAfter, hasCreatedAfter := p.Args["createdAfter"].(time.Time)
+	createdBefore, hasCreatedBefore := p.Args["createdBefore"].(time.Time)
+
 	// Build the SQL query with proper indexing
 	var baseQuery strings.Builder
 	var countQuery strings.Builder
@@ -121,8 +399,7 @@ func (r *Resolver) GetArticles(p graphql.ResolveParams) (interface{}, error) {
 	argIndex := 1
 
 	baseQuery.WriteString(`
-        SELECT a.id, a.title, a.body, a.author_id, a.created_at, 
-               au.id, au.name
+        SELECT ` + articleColumns + `
         FROM articles a
         JOIN authors au ON a.author_id = au.id
     `)
@@ -136,6 +413,24 @@ func (r *Resolver) GetArticles(p graphql.ResolveParams) (interface{}, error) {
 	// Build WHERE clause
 	var whereConditions []string
 
+	// Unpublished articles are only listed for those who may edit them
+	principal, _ := auth.FromContext(p.Context)
+	switch {
+	case principal != nil && principal.HasRole(auth.RoleEditor):
+	case principal != nil && principal.HasRole(auth.RoleAuthor):
+		whereConditions = append(whereConditions, fmt.Sprintf("(a.status = 'PUBLISHED' OR au.subject = $%d)", argIndex))
+		args = append(args, principal.Subject)
+		argIndex++
+	default:
+		whereConditions = append(whereConditions, "a.status = 'PUBLISHED'")
+	}
+
+	if hasStatusFilter {
+		whereConditions = append(whereConditions, fmt.Sprintf("a.status = $%d", argIndex))
+		args = append(args, statusFilter)
+		argIndex++
+	}
+
 	// Text search using PostgreSQL full-text search
 	if queryText != "" {
 		whereConditions = append(whereConditions, fmt.Sprintf(`
@@ -147,8 +442,20 @@ func (r *Resolver) GetArticles(p graphql.ResolveParams) (interface{}, error) {
 
 	// Author filter
 	if authorFilter != "" {
-		whereConditions = append(whereConditions, fmt.Sprintf("au.name ILIKE $%d", argIndex))
-		args = append(args, "%"+authorFilter+"%")
+		whereConditions = append(whereConditions, fmt.Sprintf(`au.name ILIKE $%d ESCAPE '\'`, argIndex))
+		args = append(args, "%"+likeEscaper.Replace(authorFilter)+"%")
+		argIndex++
+	}
+
+	// Creation time range
+	if hasCreatedAfter {
+		whereConditions = append(whereConditions, fmt.Sprintf("a.created_at > $%d", argIndex))
+		args = append(args, createdAfter)
+		argIndex++
+	}
+	if hasCreatedBefore {
+		whereConditions = append(whereConditions, fmt.Sprintf("a.created_at < $%d", argIndex))
+		args = append(args, createdBefore)
 		argIndex++
 	}
 
@@ -156,7 +463,7 @@ func (r *Resolver) GetArticles(p graphql.ResolveParams) (interface{}, error) {
 	if after != nil {
 		cursorID, cursorTime, err := models.DecodeCursor(*after)
 		if err != nil {
-			return nil, fmt.Errorf("invalid cursor: %w", err)
+			return nil, apperr.BadUserInput("invalid cursor", "after")
 		}
 		whereConditions = append(whereConditions, fmt.Sprintf(`
             (a.created_at < $%d OR (a.created_at = $%d AND a.id < $%d))`, argIndex, argIndex, argIndex+1))
@@ -178,37 +485,28 @@ func (r *Resolver) GetArticles(p graphql.ResolveParams) (interface{}, error) {
 
 	// Execute count query
 	var totalCount int
-	if err := r.db.QueryRow(countQuery.String(), args[:len(args)-1]...).Scan(&totalCount); err != nil {
-		return nil, fmt.Errorf("failed to get total count: %w", err)
+	if err := r.db.QueryRowContext(p.Context, countQuery.String(), args[:len(args)-1]...).Scan(&totalCount); err != nil {
+		return nil, queryError(p.Context, fmt.Errorf("failed to get total count: %w", err))
 	}
 
 	// Execute main query
-	rows, err := r.db.Query(baseQuery.String(), args...)
+	rows, err := r.db.QueryContext(p.Context, baseQuery.String(), args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query articles: %w", err)
+		return nil, queryError(p.Context, fmt.Errorf("failed to query articles: %w", err))
 	}
 	defer rows.Close()
 
 	var articles []*models.Article
 	for rows.Next() {
-		var article models.Article
-		var author models.Author
-
-		err := rows.Scan(
-			&article.ID, &article.Title, &article.Body,
-			&article.AuthorID, &article.CreatedAt,
-			&author.ID, &author.Name,
-		)
+		article, err := scanArticle(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan article: %w", err)
+			return nil, queryError(p.Context, fmt.Errorf("failed to scan article: %w", err))
 		}
-
-		article.Author = &author
-		articles = append(articles, &article)
+		articles = append(articles, article)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+		return nil, queryError(p.Context, fmt.Errorf("error iterating rows: %w", err))
 	}
 
 	// Determine pagination info
@@ -219,21 +517,15 @@ func (r *Resolver) GetArticles(p graphql.ResolveParams) (interface{}, error) {
 
 	hasPreviousPage := after != nil
 
-	// Create edges
+	// Create edges; the images of the page are loaded together
+	images := newImageBatch(articles)
 	edges := make([]map[string]interface{}, len(articles))
 	for i, article := range articles {
 		cursor := models.EncodeCursor(article.ID, article.CreatedAt)
+		node := articleToMap(article)
+		node[articleImagesKey] = images
 		edges[i] = map[string]interface{}{
-			"node": map[string]interface{}{
-				"id":    strconv.Itoa(article.ID),
-				"title": article.Title,
-				"body":  article.Body,
-				"author": map[string]interface{}{
-					"id":   strconv.Itoa(article.Author.ID),
-					"name": article.Author.Name,
-				},
-				"createdAt": article.CreatedAt.Format(time.RFC3339),
-			},
+			"node":   node,
 			"cursor": cursor,
 		}
 	}
@@ -260,3 +552,172 @@ func (r *Resolver) GetArticles(p graphql.ResolveParams) (interface{}, error) {
 		"totalCount": totalCount,
 	}, nil
 }
+
+// SubscribeArticleCreated streams articles published from now on, optionally
+// narrowed down by the same author and query filters as GetArticles.
+func (r *Resolver) SubscribeArticleCreated(p graphql.ResolveParams) (interface{}, error) {
+	authorFilter := ""
+	if a, ok := p.Args["author"].(string); ok {
+		authorFilter = strings.ToLower(strings.TrimSpace(a))
+	}
+
+	var queryTerms []string
+	if q, ok := p.Args["query"].(string); ok {
+		queryTerms = strings.Fields(strings.ToLower(q))
+	}
+
+	ctx := p.Context
+	articles := r.broker.Subscribe(ctx)
+	events := make(chan interface{})
+
+	go func() {
+		defer close(events)
+		for article := range articles {
+			if !matchesArticleFilter(article, authorFilter, queryTerms) {
+				continue
+			}
+
+			select {
+			case events <- articleToMap(article):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// ResolveArticleCreated resolves the articleCreated field for a single event
+// produced by SubscribeArticleCreated.
+func (r *Resolver) ResolveArticleCreated(p graphql.ResolveParams) (interface{}, error) {
+	return p.Source, nil
+}
+
+// RelayArticleCreated publishes an article announced by another server instance
+// to the local subscribers, unless it has been unpublished since.
+func (r *Resolver) RelayArticleCreated(n database.ArticleNotification) {
+	if n.Origin == r.broker.InstanceID() {
+		return
+	}
+	r.articlesChanged(context.Background())
+
+	article, err := r.getArticle(context.Background(), n.ID)
+	if err != nil {
+		slog.Error("failed to relay article", "article_id", n.ID, "error", err)
+		return
+	}
+
+	if article.Status == models.StatusPublished {
+		r.broker.Publish(article)
+	}
+}
+
+func (r *Resolver) getArticle(ctx context.Context, id int) (*models.Article, error) {
+	article, err := scanArticle(r.db.QueryRowContext(ctx, `
+        SELECT `+articleColumns+`
+        FROM articles a
+        JOIN authors au ON a.author_id = au.id
+        WHERE a.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, apperr.NotFound(fmt.Sprintf("article %d not found", id))
+	}
+	if err != nil {
+		return nil, queryError(ctx, fmt.Errorf("failed to get article: %w", err))
+	}
+	return article, nil
+}
+
+// articleColumns selects an article "a" along with its author "au", in the
+// order scanArticle reads them.
+const articleColumns = `a.id, a.title, a.body, a.author_id, a.created_at, a.status, a.published_at,
+               au.id, au.name, au.email, au.subject`
+
+func scanArticle(row interface{ Scan(...interface{}) error }) (*models.Article, error) {
+	var article models.Article
+	var author models.Author
+	err := row.Scan(
+		&article.ID, &article.Title, &article.Body,
+		&article.AuthorID, &article.CreatedAt,
+		&article.Status, &article.PublishedAt,
+		&author.ID, &author.Name, &author.Email, &author.Subject,
+	)
+	if err != nil {
+		return nil, err
+	}
+	article.Author = &author
+	return &article, nil
+}
+
+// likeEscaper makes the wildcards of LIKE patterns match themselves.
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+// matchesArticleFilter mirrors the articles query filters in memory. The author
+// filter is a case-insensitive substring match like ILIKE, and every query term
+// has to appear in the title or body, approximating plainto_tsquery.
+func matchesArticleFilter(article *models.Article, authorFilter string, queryTerms []string) bool {
+	if authorFilter != "" {
+		if article.Author == nil || !strings.Contains(strings.ToLower(article.Author.Name), authorFilter) {
+			return false
+		}
+	}
+
+	text := strings.ToLower(article.Title + " " + article.Body)
+	for _, term := range queryTerms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func articleToMap(article *models.Article) map[string]interface{} {
+	node := map[string]interface{}{
+		"id":        strconv.Itoa(article.ID),
+		"title":     article.Title,
+		"body":      article.Body,
+		"createdAt": article.CreatedAt,
+		"status":    article.Status,
+	}
+
+	if article.PublishedAt != nil {
+		node["publishedAt"] = *article.PublishedAt
+	}
+	if article.Author != nil {
+		node["author"] = authorToMap(article.Author)
+	}
+
+	return node
+}
+
+func authorToMap(author *models.Author) map[string]interface{} {
+	return map[string]interface{}{
+		"id":    strconv.Itoa(author.ID),
+		"name":  author.Name,
+		"email": author.Email,
+	}
+}
+
+// parseID converts an ID argument to a database ID.
+func parseID(value interface{}, field ...string) (int, error) {
+	s, _ := value.(string)
+	id, err := strconv.Atoi(s)
+	if err != nil || id <= 0 {
+		return 0, apperr.BadUserInput(fmt.Sprintf("invalid ID %q", s), field...)
+	}
+	return id, nil
+}
+
+// queryError wraps the error of a database call made on behalf of ctx. Calls
+// abandoned by the client or cut short by the statement timeout get their own
+// codes; anything else is an internal error.
+func queryError(ctx context.Context, err error) *apperr.Error {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return apperr.Canceled(err)
+	case database.IsTimeout(err):
+		return apperr.Timeout(err)
+	}
+	return apperr.Internal(err)
+}
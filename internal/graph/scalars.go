@@ -0,0 +1,60 @@
+package graph
+
+import (
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// DateTime is an RFC 3339 timestamp. Values are always serialized in UTC with
+// sub-second precision, so a serialized value can be passed back in a filter
+// without losing precision. Inputs may use any UTC offset.
+var DateTime = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "DateTime",
+	Description: "An RFC 3339 date-time string such as `2024-01-01T12:00:00Z`, always returned in UTC.",
+	Serialize:   serializeDateTime,
+	ParseValue:  parseDateTime,
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		if value, ok := valueAST.(*ast.StringValue); ok {
+			return parseDateTime(value.Value)
+		}
+		return nil
+	},
+})
+
+func serializeDateTime(value interface{}) interface{} {
+	switch value := value.(type) {
+	case time.Time:
+		return value.UTC().Format(time.RFC3339Nano)
+	case *time.Time:
+		if value == nil {
+			return nil
+		}
+		return serializeDateTime(*value)
+	default:
+		return nil
+	}
+}
+
+// parseDateTime returns nil for invalid values, which makes graphql-go reject
+// the argument with a validation error.
+func parseDateTime(value interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil
+		}
+		return t.UTC()
+	case *string:
+		if value == nil {
+			return nil
+		}
+		return parseDateTime(*value)
+	case time.Time:
+		return value.UTC()
+	default:
+		return nil
+	}
+}
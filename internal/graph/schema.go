@@ -1,6 +1,7 @@
 package graph
 
 import (
+	"github.com/StillLearnSVN/go-graphql-articles/internal/models"
 	"github.com/graphql-go/graphql"
 )
 
@@ -15,6 +16,57 @@ func CreateSchema(resolver *Resolver) (graphql.Schema, error) {
 			"name": &graphql.Field{
 				Type: graphql.NewNonNull(graphql.String),
 			},
+			"email": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Only visible to editors and admins.",
+				Resolve:     resolver.ResolveAuthorEmail,
+			},
+		},
+	})
+
+	// Image type
+	imageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Image",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+			},
+			"url": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"contentType": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"size": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Size of the file in bytes.",
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(DateTime),
+			},
+		},
+	})
+
+	// ArticleStatus enum
+	articleStatusEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name: "ArticleStatus",
+		Values: graphql.EnumValueConfigMap{
+			"DRAFT": &graphql.EnumValueConfig{
+				Value:       models.StatusDraft,
+				Description: "Being written. New articles start out as drafts.",
+			},
+			"IN_REVIEW": &graphql.EnumValueConfig{
+				Value:       models.StatusInReview,
+				Description: "Waiting for an editor to publish it.",
+			},
+			"PUBLISHED": &graphql.EnumValueConfig{
+				Value:       models.StatusPublished,
+				Description: "Visible to everyone.",
+			},
+			"ARCHIVED": &graphql.EnumValueConfig{
+				Value:       models.StatusArchived,
+				Description: "Withdrawn and kept for reference.",
+			},
 		},
 	})
 
@@ -35,8 +87,67 @@ func CreateSchema(resolver *Resolver) (graphql.Schema, error) {
 				Type: graphql.NewNonNull(authorType),
 			},
 			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(DateTime),
+			},
+			"status": &graphql.Field{
+				Type: graphql.NewNonNull(articleStatusEnum),
+			},
+			"publishedAt": &graphql.Field{
+				Type:        DateTime,
+				Description: "When the article was last published, null unless it is published.",
+			},
+			"images": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(imageType))),
+				Resolve: resolver.ResolveArticleImages,
+			},
+		},
+	})
+
+	// ApiKeyScope enum
+	apiKeyScopeEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name: "ApiKeyScope",
+		Values: graphql.EnumValueConfigMap{
+			"READ": &graphql.EnumValueConfig{
+				Value:       "read",
+				Description: "Query articles and subscribe to new ones.",
+			},
+			"WRITE": &graphql.EnumValueConfig{
+				Value:       "write",
+				Description: "Create articles under the name of the key and edit them.",
+			},
+			"PUBLISH": &graphql.EnumValueConfig{
+				Value:       "publish",
+				Description: "Write, and publish the articles of the key.",
+			},
+		},
+	})
+
+	// ApiKey type
+	apiKeyType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ApiKey",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+			},
+			"name": &graphql.Field{
 				Type: graphql.NewNonNull(graphql.String),
 			},
+			"prefix": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "The first characters of the key, to tell keys apart.",
+			},
+			"scopes": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(apiKeyScopeEnum))),
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(DateTime),
+			},
+			"lastUsedAt": &graphql.Field{
+				Type: DateTime,
+			},
+			"revokedAt": &graphql.Field{
+				Type: DateTime,
+			},
 		},
 	})
 
@@ -98,18 +209,120 @@ func CreateSchema(resolver *Resolver) (graphql.Schema, error) {
 			"body": &graphql.InputObjectFieldConfig{
 				Type: graphql.NewNonNull(graphql.String),
 			},
-			"authorName": &graphql.InputObjectFieldConfig{
+		},
+	})
+
+	// UserError type
+	userErrorType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserError",
+		Fields: graphql.Fields{
+			"field": &graphql.Field{
+				Type: graphql.NewList(graphql.NewNonNull(graphql.String)),
+			},
+			"message": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"code": &graphql.Field{
 				Type: graphql.NewNonNull(graphql.String),
 			},
 		},
 	})
 
+	// Mutation payload types hold the result next to the user errors
+	payloadType := func(name, field string, resultType graphql.Output) *graphql.Object {
+		return graphql.NewObject(graphql.ObjectConfig{
+			Name: name,
+			Fields: graphql.Fields{
+				field: &graphql.Field{
+					Type: resultType,
+				},
+				"userErrors": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userErrorType))),
+				},
+			},
+		})
+	}
+
+	createArticlePayloadType := payloadType("CreateArticlePayload", "article", articleType)
+	updateArticlePayloadType := payloadType("UpdateArticlePayload", "article", articleType)
+	submitArticlePayloadType := payloadType("SubmitArticlePayload", "article", articleType)
+	publishArticlePayloadType := payloadType("PublishArticlePayload", "article", articleType)
+	unpublishArticlePayloadType := payloadType("UnpublishArticlePayload", "article", articleType)
+	deleteArticlePayloadType := payloadType("DeleteArticlePayload", "deletedArticleId", graphql.ID)
+	uploadImagePayloadType := payloadType("UploadImagePayload", "image", imageType)
+	updateAuthorPayloadType := payloadType("UpdateAuthorPayload", "author", authorType)
+	deleteAuthorPayloadType := payloadType("DeleteAuthorPayload", "deletedAuthorId", graphql.ID)
+
+	revokeAPIKeyPayloadType := payloadType("RevokeApiKeyPayload", "apiKey", apiKeyType)
+
+	// CreateApiKeyPayload type also returns the key, which is only shown once
+	createAPIKeyPayloadType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CreateApiKeyPayload",
+		Fields: graphql.Fields{
+			"apiKey": &graphql.Field{
+				Type: apiKeyType,
+			},
+			"key": &graphql.Field{
+				Type:        graphql.String,
+				Description: "The secret key. It is not stored and cannot be retrieved again.",
+			},
+			"userErrors": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userErrorType))),
+			},
+		},
+	})
+
+	// UpdateArticleInput type, omitted fields are left unchanged
+	updateArticleInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UpdateArticleInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title": &graphql.InputObjectFieldConfig{
+				Type: graphql.String,
+			},
+			"body": &graphql.InputObjectFieldConfig{
+				Type: graphql.String,
+			},
+			"status": &graphql.InputObjectFieldConfig{
+				Type:        articleStatusEnum,
+				Description: "Any status but PUBLISHED, which takes publishArticle. Only editors can change the status of a published article.",
+			},
+		},
+	})
+
+	// AuthorInput type, omitted fields are left unchanged
+	authorInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "AuthorInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name": &graphql.InputObjectFieldConfig{
+				Type: graphql.String,
+			},
+			"email": &graphql.InputObjectFieldConfig{
+				Type: graphql.String,
+			},
+		},
+	})
+
+	// CreateApiKeyInput type
+	createAPIKeyInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CreateApiKeyInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name": &graphql.InputObjectFieldConfig{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "Author name shown on articles created with the key. The articles belong to the key, whatever its name.",
+			},
+			"scopes": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(apiKeyScopeEnum))),
+			},
+		},
+	})
+
 	// Query type
 	queryType := graphql.NewObject(graphql.ObjectConfig{
 		Name: "Query",
 		Fields: graphql.Fields{
 			"articles": &graphql.Field{
-				Type: graphql.NewNonNull(articleConnectionType),
+				Type:        graphql.NewNonNull(articleConnectionType),
+				Description: "Published articles, along with the unpublished articles the caller may edit.",
 				Args: graphql.FieldConfigArgument{
 					"first": &graphql.ArgumentConfig{
 						Type: graphql.Int,
@@ -123,9 +336,23 @@ func CreateSchema(resolver *Resolver) (graphql.Schema, error) {
 					"author": &graphql.ArgumentConfig{
 						Type: graphql.String,
 					},
+					"status": &graphql.ArgumentConfig{
+						Type: articleStatusEnum,
+					},
+					"createdAfter": &graphql.ArgumentConfig{
+						Type: DateTime,
+					},
+					"createdBefore": &graphql.ArgumentConfig{
+						Type: DateTime,
+					},
 				},
 				Resolve: resolver.GetArticles,
 			},
+			"apiKeys": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(apiKeyType))),
+				Description: "All API keys, including revoked ones. Admins only.",
+				Resolve:     resolver.GetAPIKeys,
+			},
 		},
 	})
 
@@ -134,7 +361,7 @@ func CreateSchema(resolver *Resolver) (graphql.Schema, error) {
 		Name: "Mutation",
 		Fields: graphql.Fields{
 			"createArticle": &graphql.Field{
-				Type: graphql.NewNonNull(articleType),
+				Type: graphql.NewNonNull(createArticlePayloadType),
 				Args: graphql.FieldConfigArgument{
 					"input": &graphql.ArgumentConfig{
 						Type: graphql.NewNonNull(articleInputType),
@@ -142,11 +369,136 @@ func CreateSchema(resolver *Resolver) (graphql.Schema, error) {
 				},
 				Resolve: resolver.CreateArticle,
 			},
+			"updateArticle": &graphql.Field{
+				Type: graphql.NewNonNull(updateArticlePayloadType),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"input": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(updateArticleInputType),
+					},
+				},
+				Resolve: resolver.UpdateArticle,
+			},
+			"submitArticle": &graphql.Field{
+				Type:        graphql.NewNonNull(submitArticlePayloadType),
+				Description: "Asks the editors to review a draft. Authors submit their own drafts.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+				},
+				Resolve: resolver.SubmitArticle,
+			},
+			"publishArticle": &graphql.Field{
+				Type:        graphql.NewNonNull(publishArticlePayloadType),
+				Description: "Makes an article visible to everyone and announces it to subscribers. Editors publish any article, publishers their own.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+				},
+				Resolve: resolver.PublishArticle,
+			},
+			"unpublishArticle": &graphql.Field{
+				Type:        graphql.NewNonNull(unpublishArticlePayloadType),
+				Description: "Turns a published article back into a draft. Editors only.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+				},
+				Resolve: resolver.UnpublishArticle,
+			},
+			"deleteArticle": &graphql.Field{
+				Type: graphql.NewNonNull(deleteArticlePayloadType),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+				},
+				Resolve: resolver.DeleteArticle,
+			},
+			"uploadImage": &graphql.Field{
+				Type:        graphql.NewNonNull(uploadImagePayloadType),
+				Description: "Adds a PNG, JPEG, GIF or WebP image to an article. Send it as a multipart request.",
+				Args: graphql.FieldConfigArgument{
+					"articleId": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"file": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(Upload),
+					},
+				},
+				Resolve: resolver.UploadImage,
+			},
+			"updateAuthor": &graphql.Field{
+				Type: graphql.NewNonNull(updateAuthorPayloadType),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"input": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(authorInputType),
+					},
+				},
+				Resolve: resolver.UpdateAuthor,
+			},
+			"deleteAuthor": &graphql.Field{
+				Type: graphql.NewNonNull(deleteAuthorPayloadType),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+				},
+				Resolve: resolver.DeleteAuthor,
+			},
+			"createApiKey": &graphql.Field{
+				Type: graphql.NewNonNull(createAPIKeyPayloadType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(createAPIKeyInputType),
+					},
+				},
+				Resolve: resolver.CreateAPIKey,
+			},
+			"revokeApiKey": &graphql.Field{
+				Type: graphql.NewNonNull(revokeAPIKeyPayloadType),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+				},
+				Resolve: resolver.RevokeAPIKey,
+			},
+		},
+	})
+
+	// Subscription type
+	subscriptionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"articleCreated": &graphql.Field{
+				Type:        graphql.NewNonNull(articleType),
+				Description: "Articles as they are published.",
+				Args: graphql.FieldConfigArgument{
+					"author": &graphql.ArgumentConfig{
+						Type: graphql.String,
+					},
+					"query": &graphql.ArgumentConfig{
+						Type: graphql.String,
+					},
+				},
+				Subscribe: resolver.SubscribeArticleCreated,
+				Resolve:   resolver.ResolveArticleCreated,
+			},
 		},
 	})
 
 	return graphql.NewSchema(graphql.SchemaConfig{
-		Query:    queryType,
-		Mutation: mutationType,
+		Query:        queryType,
+		Mutation:     mutationType,
+		Subscription: subscriptionType,
 	})
 }
@@ -0,0 +1,281 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// SchemaChange is a difference between two versions of the schema.
+type SchemaChange struct {
+	// Breaking changes can fail queries that clients built against the old schema.
+	Breaking    bool
+	Description string
+}
+
+func (c SchemaChange) String() string {
+	if c.Breaking {
+		return "BREAKING: " + c.Description
+	}
+	return c.Description
+}
+
+// DiffSchemas compares two schemas given in SDL and lists what changed from
+// oldSDL to newSDL, breaking changes first.
+func DiffSchemas(oldSDL, newSDL string) ([]SchemaChange, error) {
+	oldTypes, err := parseTypeDefinitions(oldSDL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse old schema: %w", err)
+	}
+	newTypes, err := parseTypeDefinitions(newSDL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse new schema: %w", err)
+	}
+
+	var d schemaDiff
+	for name, oldDef := range oldTypes {
+		newDef, ok := newTypes[name]
+		if !ok {
+			d.breaking("type %s was removed", name)
+			continue
+		}
+		if oldDef.GetKind() != newDef.GetKind() {
+			d.breaking("type %s changed kind from %s to %s", name, oldDef.GetKind(), newDef.GetKind())
+			continue
+		}
+		d.compareType(name, oldDef, newDef)
+	}
+	for name := range newTypes {
+		if _, ok := oldTypes[name]; !ok {
+			d.safe("type %s was added", name)
+		}
+	}
+
+	sort.SliceStable(d.changes, func(i, j int) bool {
+		if d.changes[i].Breaking != d.changes[j].Breaking {
+			return d.changes[i].Breaking
+		}
+		return d.changes[i].Description < d.changes[j].Description
+	})
+	return d.changes, nil
+}
+
+func parseTypeDefinitions(sdl string) (map[string]ast.Node, error) {
+	doc, err := parser.Parse(parser.ParseParams{Source: sdl})
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[string]ast.Node)
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.ObjectDefinition:
+			types[def.Name.Value] = def
+		case *ast.InterfaceDefinition:
+			types[def.Name.Value] = def
+		case *ast.InputObjectDefinition:
+			types[def.Name.Value] = def
+		case *ast.EnumDefinition:
+			types[def.Name.Value] = def
+		case *ast.UnionDefinition:
+			types[def.Name.Value] = def
+		case *ast.ScalarDefinition:
+			types[def.Name.Value] = def
+		}
+	}
+	return types, nil
+}
+
+type schemaDiff struct {
+	changes []SchemaChange
+}
+
+func (d *schemaDiff) breaking(format string, args ...interface{}) {
+	d.changes = append(d.changes, SchemaChange{Breaking: true, Description: fmt.Sprintf(format, args...)})
+}
+
+func (d *schemaDiff) safe(format string, args ...interface{}) {
+	d.changes = append(d.changes, SchemaChange{Description: fmt.Sprintf(format, args...)})
+}
+
+func (d *schemaDiff) compareType(name string, oldDef, newDef ast.Node) {
+	switch oldDef := oldDef.(type) {
+	case *ast.ObjectDefinition:
+		d.compareFields(name, oldDef.Fields, newDef.(*ast.ObjectDefinition).Fields)
+	case *ast.InterfaceDefinition:
+		d.compareFields(name, oldDef.Fields, newDef.(*ast.InterfaceDefinition).Fields)
+	case *ast.InputObjectDefinition:
+		d.compareInputValues(name, "input field", oldDef.Fields, newDef.(*ast.InputObjectDefinition).Fields)
+	case *ast.EnumDefinition:
+		oldValues := make(map[string]bool)
+		for _, value := range oldDef.Values {
+			oldValues[value.Name.Value] = true
+		}
+		newValues := make(map[string]bool)
+		for _, value := range newDef.(*ast.EnumDefinition).Values {
+			newValues[value.Name.Value] = true
+			if !oldValues[value.Name.Value] {
+				d.safe("enum value %s.%s was added", name, value.Name.Value)
+			}
+		}
+		for value := range oldValues {
+			if !newValues[value] {
+				d.breaking("enum value %s.%s was removed", name, value)
+			}
+		}
+	case *ast.UnionDefinition:
+		oldMembers := make(map[string]bool)
+		for _, member := range oldDef.Types {
+			oldMembers[member.Name.Value] = true
+		}
+		newMembers := make(map[string]bool)
+		for _, member := range newDef.(*ast.UnionDefinition).Types {
+			newMembers[member.Name.Value] = true
+			if !oldMembers[member.Name.Value] {
+				d.safe("type %s was added to union %s", member.Name.Value, name)
+			}
+		}
+		for member := range oldMembers {
+			if !newMembers[member] {
+				d.breaking("type %s was removed from union %s", member, name)
+			}
+		}
+	}
+}
+
+func (d *schemaDiff) compareFields(typeName string, oldFields, newFields []*ast.FieldDefinition) {
+	newByName := make(map[string]*ast.FieldDefinition)
+	for _, field := range newFields {
+		newByName[field.Name.Value] = field
+	}
+	oldByName := make(map[string]*ast.FieldDefinition)
+	for _, field := range oldFields {
+		oldByName[field.Name.Value] = field
+	}
+
+	for _, oldField := range oldFields {
+		path := typeName + "." + oldField.Name.Value
+		newField, ok := newByName[oldField.Name.Value]
+		if !ok {
+			d.breaking("field %s was removed", path)
+			continue
+		}
+
+		oldType, newType := typeString(oldField.Type), typeString(newField.Type)
+		if oldType != newType {
+			if isSafeOutputChange(oldField.Type, newField.Type) {
+				d.safe("field %s changed type from %s to %s", path, oldType, newType)
+			} else {
+				d.breaking("field %s changed type from %s to %s", path, oldType, newType)
+			}
+		}
+
+		d.compareInputValues(path, "argument", oldField.Arguments, newField.Arguments)
+	}
+
+	for _, newField := range newFields {
+		if _, ok := oldByName[newField.Name.Value]; !ok {
+			d.safe("field %s.%s was added", typeName, newField.Name.Value)
+		}
+	}
+}
+
+// compareInputValues compares the arguments of a field or the fields of an
+// input object, which both flow from the client to the server.
+func (d *schemaDiff) compareInputValues(owner, kind string, oldValues, newValues []*ast.InputValueDefinition) {
+	oldByName := make(map[string]*ast.InputValueDefinition)
+	for _, value := range oldValues {
+		oldByName[value.Name.Value] = value
+	}
+	newByName := make(map[string]*ast.InputValueDefinition)
+	for _, value := range newValues {
+		newByName[value.Name.Value] = value
+	}
+
+	for _, oldValue := range oldValues {
+		newValue, ok := newByName[oldValue.Name.Value]
+		if !ok {
+			d.breaking("%s %s.%s was removed", kind, owner, oldValue.Name.Value)
+			continue
+		}
+
+		oldType, newType := typeString(oldValue.Type), typeString(newValue.Type)
+		if oldType == newType {
+			continue
+		}
+		if isSafeInputChange(oldValue.Type, newValue.Type) {
+			d.safe("%s %s.%s changed type from %s to %s", kind, owner, oldValue.Name.Value, oldType, newType)
+		} else {
+			d.breaking("%s %s.%s changed type from %s to %s", kind, owner, oldValue.Name.Value, oldType, newType)
+		}
+	}
+
+	for _, newValue := range newValues {
+		if _, ok := oldByName[newValue.Name.Value]; ok {
+			continue
+		}
+		if _, required := newValue.Type.(*ast.NonNull); required && newValue.DefaultValue == nil {
+			d.breaking("required %s %s.%s was added", kind, owner, newValue.Name.Value)
+		} else {
+			d.safe("%s %s.%s was added", kind, owner, newValue.Name.Value)
+		}
+	}
+}
+
+// isSafeOutputChange reports whether clients reading a value of oldType can
+// also read newType. Output types may only become stricter (nullable to
+// non-null), never looser or different.
+func isSafeOutputChange(oldType, newType ast.Type) bool {
+	switch oldType := oldType.(type) {
+	case *ast.Named:
+		if newNonNull, ok := newType.(*ast.NonNull); ok {
+			return isSafeOutputChange(oldType, newNonNull.Type)
+		}
+		newNamed, ok := newType.(*ast.Named)
+		return ok && newNamed.Name.Value == oldType.Name.Value
+	case *ast.List:
+		if newNonNull, ok := newType.(*ast.NonNull); ok {
+			return isSafeOutputChange(oldType, newNonNull.Type)
+		}
+		newList, ok := newType.(*ast.List)
+		return ok && isSafeOutputChange(oldType.Type, newList.Type)
+	case *ast.NonNull:
+		newNonNull, ok := newType.(*ast.NonNull)
+		return ok && isSafeOutputChange(oldType.Type, newNonNull.Type)
+	}
+	return false
+}
+
+// isSafeInputChange reports whether values clients sent for oldType are still
+// accepted as newType. Input types may only become looser (non-null to
+// nullable), since tightening nullability rejects requests that omit the value.
+func isSafeInputChange(oldType, newType ast.Type) bool {
+	switch oldType := oldType.(type) {
+	case *ast.Named:
+		newNamed, ok := newType.(*ast.Named)
+		return ok && newNamed.Name.Value == oldType.Name.Value
+	case *ast.List:
+		newList, ok := newType.(*ast.List)
+		return ok && isSafeInputChange(oldType.Type, newList.Type)
+	case *ast.NonNull:
+		if newNonNull, ok := newType.(*ast.NonNull); ok {
+			return isSafeInputChange(oldType.Type, newNonNull.Type)
+		}
+		return isSafeInputChange(oldType.Type, newType)
+	}
+	return false
+}
+
+func typeString(t ast.Type) string {
+	switch t := t.(type) {
+	case *ast.Named:
+		return t.Name.Value
+	case *ast.List:
+		return "[" + typeString(t.Type) + "]"
+	case *ast.NonNull:
+		return typeString(t.Type) + "!"
+	}
+	return ""
+}
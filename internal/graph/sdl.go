@@ -0,0 +1,190 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// builtInScalars are part of every schema and therefore left out of the SDL.
+var builtInScalars = map[string]bool{
+	"String":  true,
+	"Int":     true,
+	"Float":   true,
+	"Boolean": true,
+	"ID":      true,
+}
+
+// PrintSchema renders the schema in the GraphQL schema definition language.
+// Types, fields and arguments are sorted by name so the output is stable and
+// can be committed as a snapshot.
+func PrintSchema(schema graphql.Schema) string {
+	var blocks []string
+
+	if block := printSchemaDefinition(schema); block != "" {
+		blocks = append(blocks, block)
+	}
+
+	typeMap := schema.TypeMap()
+	names := make([]string, 0, len(typeMap))
+	for name := range typeMap {
+		if strings.HasPrefix(name, "__") || builtInScalars[name] {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if block := printType(typeMap[name]); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+// printSchemaDefinition only prints a schema block when the root types do not
+// use the conventional names.
+func printSchemaDefinition(schema graphql.Schema) string {
+	roots := []struct {
+		operation string
+		object    *graphql.Object
+	}{
+		{"query", schema.QueryType()},
+		{"mutation", schema.MutationType()},
+		{"subscription", schema.SubscriptionType()},
+	}
+
+	conventional := true
+	var lines []string
+	for _, root := range roots {
+		if root.object == nil {
+			continue
+		}
+		if root.object.Name() != strings.ToUpper(root.operation[:1])+root.operation[1:] {
+			conventional = false
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", root.operation, root.object.Name()))
+	}
+
+	if conventional {
+		return ""
+	}
+	return "schema {\n" + strings.Join(lines, "\n") + "\n}"
+}
+
+func printType(t graphql.Type) string {
+	switch t := t.(type) {
+	case *graphql.Scalar:
+		return printDescription(t.Description(), "") + "scalar " + t.Name()
+
+	case *graphql.Object:
+		header := "type " + t.Name()
+		if len(t.Interfaces()) > 0 {
+			names := make([]string, len(t.Interfaces()))
+			for i, iface := range t.Interfaces() {
+				names[i] = iface.Name()
+			}
+			header += " implements " + strings.Join(names, " & ")
+		}
+		return printDescription(t.Description(), "") + header + printFields(t.Fields())
+
+	case *graphql.Interface:
+		return printDescription(t.Description(), "") + "interface " + t.Name() + printFields(t.Fields())
+
+	case *graphql.Union:
+		names := make([]string, len(t.Types()))
+		for i, member := range t.Types() {
+			names[i] = member.Name()
+		}
+		return printDescription(t.Description(), "") + "union " + t.Name() + " = " + strings.Join(names, " | ")
+
+	case *graphql.Enum:
+		// Values are kept in a map by the library, so sort them as well
+		values := append([]*graphql.EnumValueDefinition(nil), t.Values()...)
+		sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+
+		var lines []string
+		for _, value := range values {
+			lines = append(lines, printDescription(value.Description, "  ")+"  "+value.Name+printDeprecated(value.DeprecationReason))
+		}
+		return printDescription(t.Description(), "") + "enum " + t.Name() + " {\n" + strings.Join(lines, "\n") + "\n}"
+
+	case *graphql.InputObject:
+		fields := t.Fields()
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var lines []string
+		for _, name := range names {
+			field := fields[name]
+			lines = append(lines, printDescription(field.Description(), "  ")+"  "+printInputValue(name, field.Type, field.DefaultValue))
+		}
+		return printDescription(t.Description(), "") + "input " + t.Name() + " {\n" + strings.Join(lines, "\n") + "\n}"
+	}
+
+	return ""
+}
+
+func printFields(fields graphql.FieldDefinitionMap) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		field := fields[name]
+
+		args := ""
+		if len(field.Args) > 0 {
+			sorted := append([]*graphql.Argument(nil), field.Args...)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name() < sorted[j].Name() })
+
+			printed := make([]string, len(sorted))
+			for i, arg := range sorted {
+				printed[i] = printInputValue(arg.Name(), arg.Type, arg.DefaultValue)
+			}
+			args = "(" + strings.Join(printed, ", ") + ")"
+		}
+
+		lines = append(lines, printDescription(field.Description, "  ")+
+			"  "+name+args+": "+field.Type.String()+printDeprecated(field.DeprecationReason))
+	}
+
+	return " {\n" + strings.Join(lines, "\n") + "\n}"
+}
+
+func printInputValue(name string, t graphql.Input, defaultValue interface{}) string {
+	printed := name + ": " + t.String()
+	if defaultValue != nil {
+		if value, err := json.Marshal(defaultValue); err == nil {
+			printed += " = " + string(value)
+		}
+	}
+	return printed
+}
+
+func printDescription(description, indent string) string {
+	if description == "" {
+		return ""
+	}
+	escaped := strings.ReplaceAll(description, `"""`, `\"""`)
+	return indent + `"""` + escaped + `"""` + "\n"
+}
+
+func printDeprecated(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	quoted, _ := json.Marshal(reason)
+	return " @deprecated(reason: " + string(quoted) + ")"
+}
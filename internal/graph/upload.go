@@ -0,0 +1,228 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	// maxUploadFiles is the number of files a request may carry.
+	maxUploadFiles = 10
+	// maxUploadMemory is how much of the uploaded files is kept in memory;
+	// the rest is buffered in temporary files.
+	maxUploadMemory = 1 << 20
+)
+
+// Upload is a file sent along with a GraphQL multipart request. The
+// middleware of MultipartRequests replaces each file of the request by a
+// reference to it, which resolvers turn back into the file with
+// uploadedFile.
+var Upload = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Upload",
+	Description: "A file sent in a multipart request, following the GraphQL multipart request specification.",
+	// Files are only ever sent by clients
+	Serialize: func(interface{}) interface{} {
+		return nil
+	},
+	ParseValue: func(value interface{}) interface{} {
+		if ref, ok := value.(string); ok {
+			return uploadRef(ref)
+		}
+		return nil
+	},
+	// Files cannot be written inline in a document
+	ParseLiteral: func(ast.Value) interface{} {
+		return nil
+	},
+})
+
+// uploadRef is the value of an Upload argument, naming the file in the
+// request.
+type uploadRef string
+
+// uploadsKey stores the files of the request in the context.
+type uploadsKey struct{}
+
+// uploadedFile returns the file an Upload argument refers to, or a
+// BAD_USER_INPUT error for field when the request carries no such file.
+func uploadedFile(ctx context.Context, value interface{}, field ...string) (*multipart.FileHeader, error) {
+	ref, _ := value.(uploadRef)
+	files, _ := ctx.Value(uploadsKey{}).(map[string]*multipart.FileHeader)
+	if file, ok := files[string(ref)]; ok {
+		return file, nil
+	}
+	return nil, apperr.BadUserInput("the file must be sent in a multipart request", field...)
+}
+
+// MultipartRequests accepts GraphQL multipart requests: a multipart/form-data
+// POST holding the operations as JSON, a map from each file field to the
+// variables it is the value of, and the files. The request is passed on as a
+// JSON request, single or batched, whose Upload variables refer to the files.
+//
+// Only authors may upload files, so other callers are turned away before
+// the body is read. limit, when set, charges the request as a mutation
+// before the files are buffered, on top of the operations it carries.
+// Requests larger than maxRequestSize and files larger than maxFileSize are
+// rejected with status 413.
+func MultipartRequests(maxRequestSize, maxFileSize int64, limit func(r *http.Request, operationType string) error) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if r.Method != http.MethodPost || mediaType != "multipart/form-data" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if _, err := requireRole(r.Context(), auth.RoleAuthor); err != nil {
+				status := http.StatusForbidden
+				if apperr.As(err).Code == apperr.CodeUnauthenticated {
+					status = http.StatusUnauthorized
+				}
+				apperr.WriteHTTP(w, status, apperr.As(err))
+				return
+			}
+			if limit != nil {
+				if err := limit(r, ast.OperationTypeMutation); err != nil {
+					apperr.WriteHTTP(w, http.StatusTooManyRequests, apperr.As(err))
+					return
+				}
+			}
+
+			// The limit may be hit within part headers, whose errors do not
+			// tell, so announced sizes are checked up front
+			if r.ContentLength > maxRequestSize {
+				apperr.WriteHTTP(w, http.StatusRequestEntityTooLarge, apperr.BadUserInput("request body is too large"))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+			if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					apperr.WriteHTTP(w, http.StatusRequestEntityTooLarge, apperr.BadUserInput("request body is too large"))
+					return
+				}
+				apperr.WriteHTTP(w, http.StatusBadRequest, apperr.BadUserInput("invalid multipart request"))
+				return
+			}
+			defer r.MultipartForm.RemoveAll()
+
+			body, files, err := parseMultipartRequest(r.MultipartForm, maxFileSize)
+			if err != nil {
+				status := http.StatusBadRequest
+				if errors.Is(err, errFileTooLarge) {
+					status = http.StatusRequestEntityTooLarge
+				}
+				apperr.WriteHTTP(w, status, apperr.BadUserInput(err.Error()))
+				return
+			}
+
+			req := r.Clone(context.WithValue(r.Context(), uploadsKey{}, files))
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Del("Content-Length")
+			req.MultipartForm = nil
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+var errFileTooLarge = errors.New("file is too large")
+
+// parseMultipartRequest returns the operations of form as JSON, with the
+// values mapped to files replaced by references to them, and the files by
+// reference.
+func parseMultipartRequest(form *multipart.Form, maxFileSize int64) ([]byte, map[string]*multipart.FileHeader, error) {
+	if len(form.Value["operations"]) != 1 || len(form.Value["map"]) != 1 {
+		return nil, nil, fmt.Errorf("multipart request must have one operations and one map field")
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(form.Value["operations"][0]))
+	decoder.UseNumber()
+	var operations interface{}
+	if err := decoder.Decode(&operations); err != nil {
+		return nil, nil, fmt.Errorf("operations must be a JSON request or batch")
+	}
+	var fileMap map[string][]string
+	if err := json.Unmarshal([]byte(form.Value["map"][0]), &fileMap); err != nil {
+		return nil, nil, fmt.Errorf("map must be a JSON object of paths")
+	}
+	if len(fileMap) > maxUploadFiles {
+		return nil, nil, fmt.Errorf("at most %d files may be uploaded at once", maxUploadFiles)
+	}
+
+	files := make(map[string]*multipart.FileHeader, len(fileMap))
+	for name, paths := range fileMap {
+		headers := form.File[name]
+		if len(headers) != 1 {
+			return nil, nil, fmt.Errorf("file %q is missing", name)
+		}
+		if headers[0].Size > maxFileSize {
+			return nil, nil, fmt.Errorf("%w: %q exceeds %d bytes", errFileTooLarge, headers[0].Filename, maxFileSize)
+		}
+		files[name] = headers[0]
+
+		for _, path := range paths {
+			if !setPath(operations, strings.Split(path, "."), name) {
+				return nil, nil, fmt.Errorf("map path %q does not exist in operations", path)
+			}
+		}
+	}
+
+	body, err := json.Marshal(operations)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, files, nil
+}
+
+// setPath replaces the null value at path in v, made of object keys and
+// array indexes, by value. It reports false when there is no such value.
+func setPath(v interface{}, path []string, value string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	switch container := v.(type) {
+	case map[string]interface{}:
+		current, ok := container[path[0]]
+		if !ok {
+			return false
+		}
+		if len(path) == 1 {
+			if current != nil {
+				return false
+			}
+			container[path[0]] = value
+			return true
+		}
+		return setPath(current, path[1:], value)
+	case []interface{}:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(container) {
+			return false
+		}
+		if len(path) == 1 {
+			if container[i] != nil {
+				return false
+			}
+			container[i] = value
+			return true
+		}
+		return setPath(container[i], path[1:], value)
+	}
+	return false
+}
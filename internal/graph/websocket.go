@@ -0,0 +1,394 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// Message types and close codes of the graphql-transport-ws protocol, see
+// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+const (
+	wsProtocol = "graphql-transport-ws"
+
+	wsConnectionInit = "connection_init"
+	wsConnectionAck  = "connection_ack"
+	wsPing           = "ping"
+	wsPong           = "pong"
+	wsSubscribe      = "subscribe"
+	wsNext           = "next"
+	wsError          = "error"
+	wsComplete       = "complete"
+
+	wsCloseBadRequest         = 4400
+	wsCloseUnauthorized       = 4401
+	wsCloseForbidden          = 4403
+	wsCloseBadProtocol        = 4406
+	wsCloseInitTimeout        = 4408
+	wsCloseSubscriberExists   = 4409
+	wsCloseTooManyInitRequest = 4429
+)
+
+const wsInitTimeout = 10 * time.Second
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type wsSubscribePayload struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// ConnectionInitFunc validates the payload of a connection_init message. The
+// returned context, e.g. carrying the authenticated principal, is used for all
+// operations of the connection. An error closes the connection.
+type ConnectionInitFunc func(ctx context.Context, payload map[string]interface{}) (context.Context, error)
+
+// WebSocketHandler serves GraphQL operations, subscriptions in particular, over
+// the graphql-transport-ws protocol. Plain HTTP requests are passed on to next.
+type WebSocketHandler struct {
+	schema   *graphql.Schema
+	next     http.Handler
+	upgrader websocket.Upgrader
+
+	// InitFunc is called with the connection_init payload when set.
+	InitFunc ConnectionInitFunc
+	// CheckOrigin reports whether the Origin of an upgrade request is allowed.
+	// Browsers do not apply CORS to WebSockets, so it should follow the CORS
+	// policy of the HTTP endpoint. All origins are allowed when nil.
+	CheckOrigin func(r *http.Request) bool
+	// DisableIntrospection rejects operations selecting __schema or __type.
+	DisableIntrospection bool
+	// LimitOperation is called before each query or mutation sent over a
+	// connection, with the upgrade request carrying the context of the
+	// connection. An error is sent back instead of running the operation.
+	// Subscriptions are only limited like any other HTTP request, when the
+	// connection is opened.
+	LimitOperation func(r *http.Request, operationType string) error
+}
+
+func NewWebSocketHandler(schema *graphql.Schema, next http.Handler) *WebSocketHandler {
+	h := &WebSocketHandler{
+		schema: schema,
+		next:   next,
+	}
+	h.upgrader = websocket.Upgrader{
+		Subprotocols: []string{wsProtocol},
+		CheckOrigin: func(r *http.Request) bool {
+			return h.CheckOrigin == nil || h.CheckOrigin(r)
+		},
+	}
+	return h
+}
+
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already replied with an HTTP error
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	c := &wsConnection{
+		conn:                 conn,
+		request:              r,
+		schema:               h.schema,
+		initFunc:             h.InitFunc,
+		disableIntrospection: h.DisableIntrospection,
+		limitOperation:       h.LimitOperation,
+		subscriptions:        make(map[string]context.CancelFunc),
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != wsProtocol {
+		c.close(wsCloseBadProtocol, "Subprotocol not acceptable")
+		return
+	}
+
+	c.serve(ctx)
+}
+
+type wsConnection struct {
+	conn                 *websocket.Conn
+	request              *http.Request
+	schema               *graphql.Schema
+	initFunc             ConnectionInitFunc
+	disableIntrospection bool
+	limitOperation       func(r *http.Request, operationType string) error
+
+	writeMu sync.Mutex
+
+	mu            sync.Mutex
+	acknowledged  bool
+	subscriptions map[string]context.CancelFunc
+}
+
+func (c *wsConnection) serve(ctx context.Context) {
+	initTimer := time.AfterFunc(wsInitTimeout, func() {
+		c.mu.Lock()
+		acknowledged := c.acknowledged
+		c.mu.Unlock()
+
+		if !acknowledged {
+			c.close(wsCloseInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer initTimer.Stop()
+
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				c.close(wsCloseBadRequest, "Invalid message received")
+			}
+			return
+		}
+
+		switch msg.Type {
+		case wsConnectionInit:
+			c.mu.Lock()
+			alreadyAcknowledged := c.acknowledged
+			c.acknowledged = true
+			c.mu.Unlock()
+
+			if alreadyAcknowledged {
+				c.close(wsCloseTooManyInitRequest, "Too many initialisation requests")
+				return
+			}
+
+			if c.initFunc != nil {
+				var payload map[string]interface{}
+				if len(msg.Payload) > 0 && json.Unmarshal(msg.Payload, &payload) != nil {
+					c.close(wsCloseBadRequest, "Invalid connection_init payload")
+					return
+				}
+
+				initCtx, err := c.initFunc(ctx, payload)
+				if err != nil {
+					c.close(wsCloseForbidden, "Forbidden")
+					return
+				}
+				ctx = initCtx
+			}
+			c.write(wsMessage{Type: wsConnectionAck})
+
+		case wsPing:
+			c.write(wsMessage{Type: wsPong})
+
+		case wsPong:
+			// Nothing to do, pongs are only a keep-alive
+
+		case wsSubscribe:
+			if !c.isAcknowledged() {
+				c.close(wsCloseUnauthorized, "Unauthorized")
+				return
+			}
+
+			var payload wsSubscribePayload
+			if msg.ID == "" || json.Unmarshal(msg.Payload, &payload) != nil {
+				c.close(wsCloseBadRequest, "Invalid subscribe message")
+				return
+			}
+
+			subCtx, ok := c.register(ctx, msg.ID)
+			if !ok {
+				c.close(wsCloseSubscriberExists, fmt.Sprintf("Subscriber for %s already exists", msg.ID))
+				return
+			}
+			go c.execute(subCtx, msg.ID, payload)
+
+		case wsComplete:
+			c.unregister(msg.ID)
+
+		default:
+			c.close(wsCloseBadRequest, fmt.Sprintf("Unexpected message type %q", msg.Type))
+			return
+		}
+	}
+}
+
+// execute runs a single operation and streams its results until it finishes
+// or the client completes it.
+func (c *wsConnection) execute(ctx context.Context, id string, payload wsSubscribePayload) {
+	defer c.unregister(id)
+
+	opType, err := OperationType(payload.Query, payload.OperationName)
+	if err != nil {
+		c.writeErrors(id, gqlerrors.FormatErrors(err))
+		return
+	}
+	if c.disableIntrospection {
+		if err := CheckIntrospection(payload.Query); err != nil {
+			c.writeErrors(id, gqlerrors.FormatErrors(err))
+			return
+		}
+	}
+
+	params := graphql.Params{
+		Schema:         *c.schema,
+		RequestString:  payload.Query,
+		VariableValues: payload.Variables,
+		OperationName:  payload.OperationName,
+		Context:        ctx,
+	}
+
+	if opType != ast.OperationTypeSubscription {
+		if c.limitOperation != nil {
+			if err := c.limitOperation(c.request.WithContext(ctx), opType); err != nil {
+				c.writeErrors(id, gqlerrors.FormatErrors(err))
+				return
+			}
+		}
+		c.writeResult(id, graphql.Do(params))
+		c.write(wsMessage{ID: id, Type: wsComplete})
+		return
+	}
+
+	first := true
+	for result := range graphql.Subscribe(params) {
+		// Keep draining so the executor goroutine can finish after cancellation.
+		if ctx.Err() != nil {
+			continue
+		}
+
+		if first && result.Data == nil && result.HasErrors() {
+			c.writeErrors(id, result.Errors)
+			return
+		}
+		first = false
+
+		c.writeResult(id, result)
+	}
+
+	if ctx.Err() == nil {
+		c.write(wsMessage{ID: id, Type: wsComplete})
+	}
+}
+
+func (c *wsConnection) isAcknowledged() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.acknowledged
+}
+
+func (c *wsConnection) register(ctx context.Context, id string) (context.Context, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.subscriptions[id]; exists {
+		return nil, false
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	c.subscriptions[id] = cancel
+	return subCtx, true
+}
+
+func (c *wsConnection) unregister(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cancel, ok := c.subscriptions[id]; ok {
+		cancel()
+		delete(c.subscriptions, id)
+	}
+}
+
+func (c *wsConnection) writeResult(id string, result *graphql.Result) {
+	result.Errors = apperr.FormatAll(result.Errors)
+	payload, err := json.Marshal(result)
+	if err != nil {
+		slog.Error("failed to encode subscription result", "error", err)
+		return
+	}
+	c.write(wsMessage{ID: id, Type: wsNext, Payload: payload})
+}
+
+func (c *wsConnection) writeErrors(id string, errs []gqlerrors.FormattedError) {
+	payload, err := json.Marshal(apperr.FormatAll(errs))
+	if err != nil {
+		slog.Error("failed to encode subscription errors", "error", err)
+		return
+	}
+	c.write(wsMessage{ID: id, Type: wsError, Payload: payload})
+}
+
+func (c *wsConnection) write(msg wsMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.conn.WriteJSON(msg); err != nil {
+		slog.Warn("failed to write WebSocket message", "error", err)
+	}
+}
+
+func (c *wsConnection) close(code int, reason string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	message := websocket.FormatCloseMessage(code, reason)
+	c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	c.conn.Close()
+}
+
+// OperationType returns the type (query, mutation or subscription) of the
+// operation that a request would execute.
+func OperationType(query, operationName string) (string, error) {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return "", err
+	}
+
+	op, err := selectOperation(doc, operationName)
+	if err != nil {
+		return "", err
+	}
+	return op.Operation, nil
+}
+
+// selectOperation returns the operation of doc that a request naming
+// operationName would execute.
+func selectOperation(doc *ast.Document, operationName string) (*ast.OperationDefinition, error) {
+	var operations []*ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		if op, ok := def.(*ast.OperationDefinition); ok {
+			operations = append(operations, op)
+		}
+	}
+
+	for _, op := range operations {
+		if operationName == "" && len(operations) == 1 {
+			return op, nil
+		}
+		if op.Name != nil && op.Name.Value == operationName {
+			return op, nil
+		}
+	}
+
+	if operationName == "" {
+		return nil, fmt.Errorf("must provide operation name if query contains multiple operations")
+	}
+	return nil, fmt.Errorf("unknown operation named %q", operationName)
+}
@@ -0,0 +1,93 @@
+// Package health serves the liveness and readiness probes of the server.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/logging"
+)
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+// Status values reported by the probes.
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// CheckResult is the outcome of a single check.
+type CheckResult struct {
+	Status string `json:"status"`
+	// Duration is how long the check took, in milliseconds.
+	Duration float64 `json:"durationMs"`
+}
+
+// Report is the JSON body of a probe response.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Live answers as long as the process is able to serve requests. It does not
+// look at dependencies, so an unreachable database does not get the process
+// restarted.
+func Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, Report{Status: StatusOK})
+	})
+}
+
+// Ready runs all checks concurrently, each limited to timeout, and answers
+// 200 when all pass or 503 otherwise, with the status of every check. Errors
+// are logged rather than answered, as the probe is not authenticated.
+func Ready(checks map[string]Check, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for name, check := range checks {
+			wg.Add(1)
+			go func(name string, check Check) {
+				defer wg.Done()
+
+				start := time.Now()
+				err := check(ctx)
+				result := CheckResult{
+					Status:   StatusOK,
+					Duration: float64(time.Since(start).Microseconds()) / 1000,
+				}
+				if err != nil {
+					result.Status = StatusUnavailable
+					logging.FromContext(r.Context()).Warn("readiness check failed", "check", name, "error", err)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				report.Checks[name] = result
+				if err != nil {
+					report.Status = StatusUnavailable
+				}
+			}(name, check)
+		}
+		wg.Wait()
+
+		writeReport(w, report)
+	})
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
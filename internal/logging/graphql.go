@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Codes logged for documents rejected before execution, which carry no code
+// of their own.
+const (
+	parseFailed      = "GRAPHQL_PARSE_FAILED"
+	validationFailed = "GRAPHQL_VALIDATION_FAILED"
+)
+
+// GraphQLExtension returns a schema extension reporting the operation and
+// the codes of its errors to the request log of Middleware. Errors returned
+// in userErrors are part of the data and are not reported. Operations
+// taking longer than slowThreshold are logged with their redacted
+// variables; zero disables it.
+func GraphQLExtension(slowThreshold time.Duration) graphql.Extension {
+	return &requestExtension{slowThreshold: slowThreshold}
+}
+
+type requestExtension struct {
+	slowThreshold time.Duration
+}
+
+// operationKey stores the *operation being executed in the context.
+type operationKey struct{}
+
+type operation struct {
+	start     time.Time
+	variables map[string]interface{}
+	name      string
+	kind      string
+	named     sync.Once
+}
+
+func (e *requestExtension) Init(ctx context.Context, p *graphql.Params) context.Context {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok && p.OperationName != "" {
+		req.mu.Lock()
+		req.operationName = p.OperationName
+		req.mu.Unlock()
+	}
+	return context.WithValue(ctx, operationKey{}, &operation{
+		start:     time.Now(),
+		variables: p.VariableValues,
+		name:      p.OperationName,
+	})
+}
+
+func (e *requestExtension) Name() string {
+	return "logging"
+}
+
+func (e *requestExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(err error) {
+		if err != nil {
+			addErrorCode(ctx, parseFailed)
+		}
+	}
+}
+
+func (e *requestExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func(errs []gqlerrors.FormattedError) {
+		if len(errs) > 0 {
+			addErrorCode(ctx, validationFailed)
+		}
+	}
+}
+
+func (e *requestExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(result *graphql.Result) {
+		for _, err := range result.Errors {
+			if code := apperr.CodeOf(err.OriginalError()); code != "" {
+				addErrorCode(ctx, string(code))
+			}
+		}
+
+		op, ok := ctx.Value(operationKey{}).(*operation)
+		if !ok || e.slowThreshold <= 0 {
+			return
+		}
+		if elapsed := time.Since(op.start); elapsed >= e.slowThreshold {
+			FromContext(ctx).LogAttrs(ctx, slog.LevelWarn, "slow operation",
+				slog.String("operation_type", op.kind),
+				slog.String("operation_name", op.name),
+				slog.Float64("duration_ms", milliseconds(elapsed)),
+				slog.Any("variables", Redact(op.variables)),
+			)
+		}
+	}
+}
+
+// ResolveFieldDidStart records the operation type, and the operation name
+// when the client did not send operationName, once the document is parsed.
+func (e *requestExtension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	op, _ := ctx.Value(operationKey{}).(*operation)
+	definition, ok := info.Operation.(*ast.OperationDefinition)
+	if op != nil && ok {
+		op.named.Do(func() {
+			op.kind = definition.Operation
+			if op.name == "" && definition.Name != nil {
+				op.name = definition.Name.Value
+			}
+			if req, ok := ctx.Value(requestKey{}).(*request); ok {
+				req.mu.Lock()
+				req.operationType = op.kind
+				req.operationName = op.name
+				req.mu.Unlock()
+			}
+		})
+	}
+	return ctx, func(interface{}, error) {}
+}
+
+func (e *requestExtension) HasResult() bool {
+	return false
+}
+
+func (e *requestExtension) GetResult(context.Context) interface{} {
+	return nil
+}
+
+func addErrorCode(ctx context.Context, code string) {
+	req, ok := ctx.Value(requestKey{}).(*request)
+	if !ok {
+		return
+	}
+	req.mu.Lock()
+	defer req.mu.Unlock()
+	for _, seen := range req.errorCodes {
+		if seen == code {
+			return
+		}
+	}
+	req.errorCodes = append(req.errorCodes, code)
+}
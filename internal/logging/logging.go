@@ -0,0 +1,132 @@
+// Package logging sets up the structured logger and logs every HTTP request
+// under a request ID, along with the GraphQL operation it carried.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/middleware"
+)
+
+// RequestIDHeader carries the request ID. An ID sent by the client or a proxy
+// in front of the server is kept, so that their logs can be correlated.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+// New returns a logger writing to w in the configured format.
+func New(w io.Writer, cfg config.Logging) *slog.Logger {
+	var level slog.Level
+	// The level was validated with the configuration
+	_ = level.UnmarshalText([]byte(cfg.Level))
+
+	opts := &slog.HandlerOptions{Level: level}
+	if cfg.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+type requestKey struct{}
+
+// request collects what is logged about a request while it is served.
+type request struct {
+	logger *slog.Logger
+
+	mu            sync.Mutex
+	operationName string
+	operationType string
+	errorCodes    []string
+}
+
+// FromContext returns the logger of the request being served, which tags
+// every entry with the request ID, or the default logger outside requests.
+func FromContext(ctx context.Context) *slog.Logger {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		return req.logger
+	}
+	return slog.Default()
+}
+
+// Middleware assigns every request an ID, echoed in the X-Request-ID response
+// header, and logs the request once it has been served. Server errors are
+// logged at the error level and client errors at the warning level.
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			req := &request{logger: logger.With("request_id", id)}
+			ctx := context.WithValue(r.Context(), requestKey{}, req)
+
+			start := time.Now()
+			recorder := middleware.NewStatusRecorder(w)
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", recorder.Status),
+				slog.Float64("duration_ms", milliseconds(time.Since(start))),
+			}
+			req.mu.Lock()
+			if req.operationType != "" {
+				attrs = append(attrs, slog.String("operation_type", req.operationType))
+			}
+			if req.operationName != "" {
+				attrs = append(attrs, slog.String("operation_name", req.operationName))
+			}
+			if len(req.errorCodes) > 0 {
+				attrs = append(attrs, slog.Any("error_codes", req.errorCodes))
+			}
+			req.mu.Unlock()
+
+			level := slog.LevelInfo
+			switch {
+			case recorder.Status >= 500:
+				level = slog.LevelError
+			case recorder.Status >= 400:
+				level = slog.LevelWarn
+			}
+			req.logger.LogAttrs(ctx, level, "request", attrs...)
+		})
+	}
+}
+
+// validRequestID accepts IDs of printable ASCII without spaces, so that
+// they cannot forge log lines or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// milliseconds converts d for the duration_ms attribute of log entries.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
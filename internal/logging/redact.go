@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// Redact returns v safe to log: numbers, booleans and times are kept, while
+// strings and values of other types, which may hold personal data or
+// secrets, are replaced by a placeholder. Strings keep their length, which
+// often explains a slow search. Maps and slices, such as GraphQL variables,
+// are redacted element by element.
+func Redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, time.Time:
+		return v
+	case string:
+		return fmt.Sprintf("[REDACTED %d chars]", utf8.RuneCountInString(v))
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			redacted[key] = Redact(value)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, value := range v {
+			redacted[i] = Redact(value)
+		}
+		return redacted
+	default:
+		return "[REDACTED]"
+	}
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// GraphQLExtension returns a schema extension measuring the top-level fields
+// of every operation, e.g. articles or createArticle. Nested fields are
+// resolved from their parent's result and are not measured separately.
+func (m *Metrics) GraphQLExtension() graphql.Extension {
+	return &resolverExtension{metrics: m}
+}
+
+type resolverExtension struct {
+	metrics *Metrics
+}
+
+func (e *resolverExtension) Init(ctx context.Context, p *graphql.Params) context.Context {
+	return ctx
+}
+
+func (e *resolverExtension) Name() string {
+	return "metrics"
+}
+
+func (e *resolverExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (e *resolverExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (e *resolverExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(*graphql.Result) {}
+}
+
+func (e *resolverExtension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	if info.Path == nil || info.Path.Prev != nil {
+		return ctx, func(interface{}, error) {}
+	}
+
+	operation := "query"
+	if definition, ok := info.Operation.(*ast.OperationDefinition); ok {
+		operation = definition.Operation
+	}
+	field := info.FieldName
+	start := time.Now()
+
+	return ctx, func(_ interface{}, err error) {
+		e.metrics.resolverDuration.WithLabelValues(operation, field).Observe(time.Since(start).Seconds())
+		if err != nil {
+			e.metrics.resolverErrors.WithLabelValues(operation, field, string(apperr.As(err).Code)).Inc()
+		}
+	}
+}
+
+func (e *resolverExtension) HasResult() bool {
+	return false
+}
+
+func (e *resolverExtension) GetResult(context.Context) interface{} {
+	return nil
+}
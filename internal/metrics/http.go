@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/middleware"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// Middleware counts requests and measures their latency. Requests are
+// labelled by the path template of their mux route rather than the path, so
+// unknown paths do not create new series. It is meant for router.Use, which
+// only wraps matched routes, so unknown paths are not counted at all.
+// WebSocket connections are left out since they last as long as the client
+// stays connected.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var route string
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+		if route == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		recorder := middleware.NewStatusRecorder(w)
+		next.ServeHTTP(recorder, r)
+
+		m.requests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.Status)).Inc()
+		m.requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
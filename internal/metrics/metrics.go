@@ -0,0 +1,72 @@
+// Package metrics collects the Prometheus metrics of the server: HTTP traffic,
+// GraphQL resolvers and, through Register, any other collector such as the
+// database pool statistics.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics owns a registry, so that tests and several servers in one process
+// do not share the global one.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	resolverDuration *prometheus.HistogramVec
+	resolverErrors   *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		resolverDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "graphql_resolver_duration_seconds",
+			Help:    "Duration of the top-level GraphQL fields by operation type and field.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "field"}),
+		resolverErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "graphql_resolver_errors_total",
+			Help: "Errors returned by the top-level GraphQL fields by operation type, field and error code.",
+		}, []string{"operation", "field", "code"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.resolverDuration,
+		m.resolverErrors,
+	)
+	return m
+}
+
+// Register adds collectors maintained elsewhere, e.g. by the database.
+func (m *Metrics) Register(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if err := m.registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
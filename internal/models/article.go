@@ -9,18 +9,28 @@ import (
 )
 
 type Article struct {
-    ID        int       `json:"id"`
-    Title     string    `json:"title"`
-    Body      string    `json:"body"`
-    AuthorID  int       `json:"author_id"`
-    Author    *Author   `json:"author,omitempty"`
-    CreatedAt time.Time `json:"created_at"`
+    ID          int        `json:"id"`
+    Title       string     `json:"title"`
+    Body        string     `json:"body"`
+    AuthorID    int        `json:"author_id"`
+    Author      *Author    `json:"author,omitempty"`
+    CreatedAt   time.Time  `json:"created_at"`
+    Status      string     `json:"status"`
+    PublishedAt *time.Time `json:"published_at,omitempty"`
 }
 
+// Article statuses. Only published articles are visible to everyone; the
+// others are only listed for their author and editors.
+const (
+    StatusDraft     = "DRAFT"
+    StatusInReview  = "IN_REVIEW"
+    StatusPublished = "PUBLISHED"
+    StatusArchived  = "ARCHIVED"
+)
+
 type ArticleInput struct {
-    Title      string `json:"title"`
-    Body       string `json:"body"`
-    AuthorName string `json:"authorName"`
+    Title string `json:"title"`
+    Body  string `json:"body"`
 }
 
 type PageInfo struct {
@@ -41,9 +51,11 @@ type ArticleConnection struct {
     TotalCount int            `json:"totalCount"`
 }
 
-// EncodeCursor creates a base64 encoded cursor from article ID and timestamp
+// EncodeCursor creates a base64 encoded cursor from article ID and timestamp.
+// The timestamp keeps the microseconds stored by the database, so articles
+// created within the same second are not skipped.
 func EncodeCursor(id int, createdAt time.Time) string {
-    cursor := fmt.Sprintf("%d:%d", id, createdAt.Unix())
+    cursor := fmt.Sprintf("%d:%d", id, createdAt.UnixMicro())
     return base64.StdEncoding.EncodeToString([]byte(cursor))
 }
 
@@ -69,5 +81,5 @@ func DecodeCursor(cursor string) (int, time.Time, error) {
         return 0, time.Time{}, err
     }
     
-    return id, time.Unix(timestamp, 0), nil
+    return id, time.UnixMicro(timestamp), nil
 }
\ No newline at end of file
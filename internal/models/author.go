@@ -1,6 +1,10 @@
 package models
 
 type Author struct {
-    ID   int    `json:"id"`
-    Name string `json:"name"`
+    ID      int     `json:"id"`
+    Name    string  `json:"name"`
+    Email   *string `json:"email,omitempty"`
+    // Subject identifies the author's token or API key; nil for authors
+    // created before subjects were recorded
+    Subject *string `json:"-"`
 }
\ No newline at end of file
@@ -0,0 +1,16 @@
+package models
+
+import (
+    "time"
+)
+
+// Image is a file uploaded for an article. Key names it in the storage
+// backend, which gives its URL.
+type Image struct {
+    ID          int       `json:"id"`
+    ArticleID   int       `json:"article_id"`
+    Key         string    `json:"key"`
+    ContentType string    `json:"content_type"`
+    Size        int64     `json:"size"`
+    CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/logging"
+)
+
+// Proxies are the reverse proxies trusted to name the client of a request in
+// the X-Forwarded-For header.
+type Proxies []netip.Prefix
+
+// ParseProxies parses IP addresses and CIDR ranges, e.g. 10.0.0.0/8.
+func ParseProxies(entries []string) (Proxies, error) {
+	proxies := make(Proxies, 0, len(entries))
+	for _, entry := range entries {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy address %q", entry)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return proxies, nil
+}
+
+func (p Proxies) trusts(addr netip.Addr) bool {
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client of r. Requests from trusted
+// proxies are attributed to the last address of X-Forwarded-For that is not
+// a trusted proxy itself, since earlier addresses may be forged by the client.
+func (p Proxies) ClientIP(r *http.Request) string {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+
+	client := peer.Addr().Unmap()
+	if !p.trusts(client) {
+		return client.String()
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !p.trusts(client) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// clientIPKey stores the IP address of the client in the context.
+type clientIPKey struct{}
+
+// Clients identifies the client of each request by IP address, looking
+// through the trusted proxies, and rejects clients that sent more than limit
+// requests with status 429. It runs before authentication, so that floods of
+// invalid credentials are stopped before they are looked up in the database.
+// The budgets of Middleware then apply per IP address to the same client.
+func Clients(store Store, limit Limit, proxies Proxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := proxies.ClientIP(r)
+			r = r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip))
+			if !limit.Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, retryAfter, err := store.Take(r.Context(), "ip:"+ip+":request", limit)
+			if err != nil {
+				logging.FromContext(r.Context()).Error("rate limit check failed", "error", err)
+			} else if !allowed {
+				seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				apperr.WriteHTTP(w, http.StatusTooManyRequests,
+					apperr.RateLimited(fmt.Sprintf("too many requests, retry in %d seconds", seconds)))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the address found by Clients, or the peer address of
+// requests that did not go through it.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return Proxies(nil).ClientIP(r)
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/logging"
+)
+
+// Limits are the budgets of each client. Queries and mutations are counted
+// separately, so that expensive writes can be limited more strictly.
+type Limits struct {
+	// Query also covers subscriptions, counted once when the WebSocket
+	// connection is opened. Queries and mutations sent over the connection
+	// are counted one by one, see Operations.
+	Query    Limit
+	Mutation Limit
+}
+
+// Middleware rejects requests of clients that exceeded their budget with
+// status 429, a Retry-After header and a RATE_LIMITED error. It has to run
+// after authentication: authenticated callers are limited per API key or
+// user, anonymous ones per IP address, as found by Clients. If the store
+// fails, requests are let through.
+func Middleware(store Store, limits Limits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The GraphQL handler runs mutations sent with any method, GET
+			// included, so the method says nothing about the operation
+			kind, retryAfter := take(store, limits, r, graph.RequestOperationType(r))
+			if retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				apperr.WriteHTTP(w, http.StatusTooManyRequests,
+					apperr.RateLimited(fmt.Sprintf("too many %s requests, retry in %d seconds", kind, retryAfter)))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Operations returns a check counting single operations against the budgets
+// of Middleware, for operations that do not come with a request of their own:
+// those sent over an open WebSocket connection. r carries the client, and
+// operationType is "query" or "mutation". Operations over budget get a
+// RATE_LIMITED error.
+func Operations(store Store, limits Limits) func(r *http.Request, operationType string) error {
+	return func(r *http.Request, operationType string) error {
+		kind, retryAfter := take(store, limits, r, operationType)
+		if retryAfter > 0 {
+			return apperr.RateLimited(fmt.Sprintf("too many %s requests, retry in %d seconds", kind, retryAfter))
+		}
+		return nil
+	}
+}
+
+// take counts an operation of the client of r against the budget of its
+// type. It returns the kind of budget and, when it is exceeded, the seconds
+// to wait before retrying; 0 lets the operation through.
+func take(store Store, limits Limits, r *http.Request, operationType string) (string, int) {
+	kind, limit := "query", limits.Query
+	if operationType == "mutation" {
+		kind, limit = "mutation", limits.Mutation
+	}
+	if !limit.Enabled() {
+		return kind, 0
+	}
+
+	allowed, retryAfter, err := store.Take(r.Context(), clientKey(r)+":"+kind, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("rate limit check failed", "error", err)
+		return kind, 0
+	}
+	if allowed {
+		return kind, 0
+	}
+	return kind, max(int(math.Ceil(retryAfter.Seconds())), 1)
+}
+
+// clientKey identifies the client a request is counted against. API key
+// principals have subjects of their own, so each key gets its own budget.
+func clientKey(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return "principal:" + principal.Subject
+	}
+	return "ip:" + clientIP(r)
+}
@@ -0,0 +1,33 @@
+// Package ratelimit throttles GraphQL requests per client with token buckets.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit configures a token bucket: a client may make Burst requests at once,
+// and regains Rate requests per second after that.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute allows n requests per minute, all of which may be made at once.
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// Enabled reports whether the limit allows any request at all. A zero Limit
+// means no limit.
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// Store keeps the token buckets. Implementations backed by a shared database
+// or cache let several server instances enforce one budget.
+type Store interface {
+	// Take removes a token from the bucket of key. When the bucket is empty
+	// it reports how long until a token is available again.
+	Take(ctx context.Context, key string, limit Limit) (allowed bool, retryAfter time.Duration, err error)
+}
@@ -0,0 +1,33 @@
+// Package storage keeps uploaded files, such as article images. Files are
+// stored under keys chosen by the server and downloaded by clients from the
+// URL the store gives for each key.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrInvalidKey is returned for keys that could escape the store, such as
+// ones holding a path separator.
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Store is a backend for uploaded files. DiskStore keeps them on the local
+// disk; S3-compatible object stores can implement it as well.
+type Store interface {
+	// Put stores the content of r under key, replacing any file stored
+	// there.
+	Put(ctx context.Context, key, contentType string, r io.Reader) error
+	// Delete removes the file stored under key. Missing files are not an
+	// error.
+	Delete(ctx context.Context, key string) error
+	// URL returns where clients download the file stored under key.
+	URL(key string) string
+}
+
+// validKey reports whether key names a file directly in the store.
+func validKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, ".") && !strings.ContainsAny(key, `/\`)
+}
@@ -0,0 +1,161 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentSchema traces every operation executed against schema, with a
+// child span for each field that has its own resolver, e.g. Query.articles or
+// Author.email. Fields read from their parent's result are not traced. Spans
+// started by the resolvers, such as SQL statements, nest under the field.
+func InstrumentSchema(schema *graphql.Schema, provider trace.TracerProvider) {
+	tracer := provider.Tracer(instrumentationName)
+	schema.AddExtensions(&operationExtension{tracer: tracer})
+
+	for name, t := range schema.TypeMap() {
+		object, ok := t.(*graphql.Object)
+		if !ok || strings.HasPrefix(name, "__") {
+			continue
+		}
+		for _, field := range object.Fields() {
+			if field.Resolve != nil {
+				field.Resolve = traceResolver(tracer, object.Name()+"."+field.Name, field.Resolve)
+			}
+		}
+	}
+}
+
+func traceResolver(tracer trace.Tracer, spanName string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		ctx, span := tracer.Start(p.Context, spanName, trace.WithAttributes(
+			attribute.String("graphql.field.path", responsePath(p.Info.Path)),
+		))
+		defer span.End()
+
+		p.Context = ctx
+		result, err := resolve(p)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.SetAttributes(attribute.String("graphql.error.code", string(apperr.As(err).Code)))
+		}
+		return result, err
+	}
+}
+
+// responsePath formats a path such as articles.edges.0.node.author.
+func responsePath(path *graphql.ResponsePath) string {
+	var keys []string
+	for ; path != nil; path = path.Prev {
+		keys = append([]string{fmt.Sprint(path.Key)}, keys...)
+	}
+	return strings.Join(keys, ".")
+}
+
+// operationKey stores the *operation being executed in the context.
+type operationKey struct{}
+
+type operation struct {
+	span  trace.Span
+	named sync.Once
+}
+
+// operationExtension opens a span when graphql-go starts on a request and
+// ends it once the request failed to parse or validate, or was executed. The
+// resolvers inherit the span through the context.
+//
+// The field hooks of graphql-go replace the context shared by all fields, so
+// resolver spans are started by traceResolver instead.
+type operationExtension struct {
+	tracer trace.Tracer
+}
+
+func (e *operationExtension) Init(ctx context.Context, p *graphql.Params) context.Context {
+	// The document is left out as its literals may hold personal data or secrets
+	var attributes []attribute.KeyValue
+	if p.OperationName != "" {
+		attributes = append(attributes, semconv.GraphQLOperationName(p.OperationName))
+	}
+
+	ctx, span := e.tracer.Start(ctx, "GraphQL Operation", trace.WithAttributes(attributes...))
+	return context.WithValue(ctx, operationKey{}, &operation{span: span})
+}
+
+func (e *operationExtension) Name() string {
+	return "tracing"
+}
+
+func (e *operationExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	op, _ := ctx.Value(operationKey{}).(*operation)
+	return ctx, func(err error) {
+		if op != nil && err != nil {
+			op.fail(err.Error())
+		}
+	}
+}
+
+func (e *operationExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	op, _ := ctx.Value(operationKey{}).(*operation)
+	return ctx, func(errs []gqlerrors.FormattedError) {
+		if op != nil && len(errs) > 0 {
+			op.fail(errs[0].Message)
+		}
+	}
+}
+
+func (e *operationExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	op, _ := ctx.Value(operationKey{}).(*operation)
+	return ctx, func(result *graphql.Result) {
+		if op == nil {
+			return
+		}
+		if len(result.Errors) > 0 {
+			op.fail(result.Errors[0].Message)
+			return
+		}
+		op.span.End()
+	}
+}
+
+// ResolveFieldDidStart names the operation span after the operation type,
+// which is only known once the document was parsed.
+func (e *operationExtension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	op, _ := ctx.Value(operationKey{}).(*operation)
+	definition, ok := info.Operation.(*ast.OperationDefinition)
+	if op != nil && ok {
+		op.named.Do(func() {
+			name := definition.Operation
+			if definition.Name != nil {
+				name += " " + definition.Name.Value
+			}
+			op.span.SetName(name)
+			op.span.SetAttributes(semconv.GraphQLOperationTypeKey.String(definition.Operation))
+		})
+	}
+	return ctx, func(interface{}, error) {}
+}
+
+func (e *operationExtension) HasResult() bool {
+	return false
+}
+
+func (e *operationExtension) GetResult(context.Context) interface{} {
+	return nil
+}
+
+func (op *operation) fail(message string) {
+	op.span.SetStatus(codes.Error, message)
+	op.span.End()
+}
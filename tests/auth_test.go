@@ -0,0 +1,220 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "test-secret"
+
+func signHS256(t *testing.T, secret string, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestVerifier_HS256(t *testing.T) {
+	verifier, err := auth.NewVerifier(auth.Config{HMACSecret: testSecret, Issuer: "articles"})
+	require.NoError(t, err)
+
+	valid := signHS256(t, testSecret, jwt.MapClaims{
+		"sub":   "42",
+		"name":  "Alice",
+		"email": "alice@example.com",
+		"roles": []string{"Author", "unknown"},
+		"iss":   "articles",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	principal, err := verifier.Verify(valid)
+	require.NoError(t, err)
+	assert.Equal(t, &auth.Principal{
+		Subject: "42",
+		Name:    "Alice",
+		Email:   "alice@example.com",
+		Roles:   []auth.Role{auth.RoleAuthor},
+	}, principal)
+
+	for name, token := range map[string]string{
+		"expired":      signHS256(t, testSecret, jwt.MapClaims{"sub": "42", "iss": "articles", "exp": time.Now().Add(-time.Minute).Unix()}),
+		"no expiry":    signHS256(t, testSecret, jwt.MapClaims{"sub": "42", "iss": "articles"}),
+		"wrong issuer": signHS256(t, testSecret, jwt.MapClaims{"sub": "42", "iss": "other", "exp": time.Now().Add(time.Hour).Unix()}),
+		"wrong secret": signHS256(t, "other-secret", jwt.MapClaims{"sub": "42", "iss": "articles", "exp": time.Now().Add(time.Hour).Unix()}),
+		"key subject":  signHS256(t, testSecret, jwt.MapClaims{"sub": "apikey:3", "iss": "articles", "exp": time.Now().Add(time.Hour).Unix()}),
+	} {
+		_, err := verifier.Verify(token)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestVerifier_RS256FromJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	require.NoError(t, err)
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks, 0o600))
+
+	verifier, err := auth.NewVerifier(auth.Config{JWKSFile: jwksFile})
+	require.NoError(t, err)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub":                "7",
+		"preferred_username": "bob",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	principal, err := verifier.Verify(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", principal.Name)
+	assert.Equal(t, []auth.Role{auth.RoleReader}, principal.Roles)
+
+	// An HS256 token must not be accepted when only RSA keys are configured
+	_, err = verifier.Verify(signHS256(t, testSecret, jwt.MapClaims{"sub": "7", "exp": time.Now().Add(time.Hour).Unix()}))
+	assert.Error(t, err)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	verifier, err := auth.NewVerifier(auth.Config{HMACSecret: testSecret})
+	require.NoError(t, err)
+
+	var seen *auth.Principal
+	handler := auth.Middleware(&auth.Credentials{Tokens: verifier})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = auth.FromContext(r.Context())
+	}))
+
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		seen = nil
+		req := httptest.NewRequest("POST", "/graphql", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// Anonymous requests pass through without a principal
+	assert.Equal(t, http.StatusOK, serve("").Code)
+	assert.Nil(t, seen)
+
+	token := signHS256(t, testSecret, jwt.MapClaims{"sub": "42", "name": "Alice", "exp": time.Now().Add(time.Hour).Unix()})
+	assert.Equal(t, http.StatusOK, serve("Bearer "+token).Code)
+	require.NotNil(t, seen)
+	assert.Equal(t, "Alice", seen.Name)
+
+	rejected := serve("Bearer not-a-token")
+	assert.Equal(t, http.StatusUnauthorized, rejected.Code)
+	assert.Contains(t, rejected.Body.String(), "UNAUTHENTICATED")
+	assert.Nil(t, seen)
+}
+
+// fakeAPIKeys is an in-memory auth.APIKeyStore.
+type fakeAPIKeys struct {
+	keys map[string]*models.APIKey
+	err  error
+}
+
+func (f *fakeAPIKeys) AuthenticateAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	key, ok := f.keys[hash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return key, nil
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+
+	assert.True(t, auth.IsAPIKey(key))
+	assert.True(t, len(key) > 40)
+	assert.Equal(t, key[:len(prefix)], prefix)
+	assert.Equal(t, auth.HashAPIKey(key), hash)
+	assert.NotContains(t, hash, key)
+
+	other, _, _, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestAuthMiddleware_APIKeys(t *testing.T) {
+	key, _, hash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+
+	store := &fakeAPIKeys{keys: map[string]*models.APIKey{
+		hash: {ID: 3, Name: "ingest", Scopes: []string{"read", "write"}},
+	}}
+
+	var seen *auth.Principal
+	handler := auth.Middleware(&auth.Credentials{APIKeys: store})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = auth.FromContext(r.Context())
+	}))
+
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		seen = nil
+		req := httptest.NewRequest("POST", "/graphql", nil)
+		req.Header.Set("Authorization", authorization)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusOK, serve("Bearer "+key).Code)
+	require.NotNil(t, seen)
+	assert.Equal(t, "apikey:3", seen.Subject)
+	assert.Equal(t, "ingest", seen.Name)
+	assert.True(t, seen.HasRole(auth.RoleAuthor))
+	assert.False(t, seen.HasRole(auth.RolePublisher))
+
+	// Keys with the publish scope may publish their articles, but not edit others'
+	store.keys[hash].Scopes = append(store.keys[hash].Scopes, "publish")
+	assert.Equal(t, http.StatusOK, serve("Bearer "+key).Code)
+	require.NotNil(t, seen)
+	assert.True(t, seen.HasRole(auth.RolePublisher))
+	assert.False(t, seen.HasRole(auth.RoleEditor))
+
+	// Unknown keys and JWTs are rejected when only API keys are accepted
+	assert.Equal(t, http.StatusUnauthorized, serve("Bearer "+auth.APIKeyPrefix+"unknown").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("Bearer not-a-token").Code)
+
+	// Failing lookups are not reported as bad credentials
+	store.err = errors.New("connection refused")
+	failed := serve("Bearer " + key)
+	assert.Equal(t, http.StatusInternalServerError, failed.Code)
+	assert.Contains(t, failed.Body.String(), "INTERNAL")
+	assert.Nil(t, seen)
+}
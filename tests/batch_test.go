@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/ratelimit"
+	"github.com/graphql-go/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchHandler(t *testing.T, maxSize, concurrency int) http.Handler {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+	return graph.Batch(maxSize, concurrency)(handler.New(&handler.Config{
+		Schema:        &schema,
+		FormatErrorFn: apperr.Format,
+	}))
+}
+
+func postJSON(h http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestBatch(t *testing.T) {
+	h := newBatchHandler(t, 3, 2)
+
+	recorder := postJSON(h, `[
+		{"query": "{ __typename }"},
+		{"query": "query Named { __typename }", "operationName": "Named"},
+		{"query": "{ apiKeys { id } }"}
+	]`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json; charset=utf-8", recorder.Header().Get("Content-Type"))
+
+	var results []map[string]interface{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &results))
+	require.Len(t, results, 3)
+	assert.Equal(t, map[string]interface{}{"__typename": "Query"}, results[0]["data"])
+	assert.Equal(t, map[string]interface{}{"__typename": "Query"}, results[1]["data"])
+	assert.Contains(t, recorder.Body.String(), "UNAUTHENTICATED")
+	assert.NotNil(t, results[2]["errors"])
+
+	// Single requests are served as before
+	single := postJSON(h, `{"query": "{ __typename }"}`)
+	assert.JSONEq(t, `{"data":{"__typename":"Query"}}`, single.Body.String())
+}
+
+func TestBatch_Rejected(t *testing.T) {
+	h := newBatchHandler(t, 2, 1)
+
+	tooLarge := postJSON(h, `[{"query": "{ __typename }"}, {"query": "{ __typename }"}, {"query": "{ __typename }"}]`)
+	assert.Equal(t, http.StatusBadRequest, tooLarge.Code)
+	assert.JSONEq(t, `{"errors": [{
+		"message": "batch of 3 operations exceeds the maximum of 2",
+		"locations": [],
+		"extensions": {"code": "BAD_USER_INPUT"}
+	}]}`, tooLarge.Body.String())
+
+	empty := postJSON(h, `[]`)
+	assert.Equal(t, http.StatusBadRequest, empty.Code)
+	assert.Contains(t, empty.Body.String(), "batch must not be empty")
+
+	malformed := postJSON(h, `[{"query": "{ __typename }"},`)
+	assert.Equal(t, http.StatusBadRequest, malformed.Code)
+	assert.Contains(t, malformed.Body.String(), "BAD_USER_INPUT")
+
+	// Batching can be turned off, leaving arrays to the GraphQL handler
+	disabled := postJSON(newBatchHandler(t, 0, 1), `[{"query": "{ __typename }"}]`)
+	assert.NotContains(t, disabled.Body.String(), `"Query"`)
+}
+
+func TestBatch_BoundsConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	h := graph.Batch(10, 2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts := graph.PeekRequest(r)
+		now := running.Add(1)
+		for {
+			seen := peak.Load()
+			if now <= seen || peak.CompareAndSwap(seen, now) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": opts.Query})
+	}))
+
+	recorder := postJSON(h, `[{"query": "a"}, {"query": "b"}, {"query": "c"}, {"query": "d"}, {"query": "e"}]`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `[{"data":"a"}, {"data":"b"}, {"data":"c"}, {"data":"d"}, {"data":"e"}]`, recorder.Body.String())
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+func TestBatch_RateLimitsEachOperation(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+	limits := ratelimit.Limits{Query: ratelimit.PerMinute(2)}
+	h := graph.Batch(10, 1)(ratelimit.Middleware(ratelimit.NewMemoryStore(), limits)(
+		handler.New(&handler.Config{Schema: &schema, FormatErrorFn: apperr.Format})))
+
+	recorder := postJSON(h, `[{"query": "{ __typename }"}, {"query": "{ __typename }"}, {"query": "{ __typename }"}]`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var results []map[string]interface{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &results))
+	require.Len(t, results, 3)
+	assert.NotNil(t, results[0]["data"])
+	assert.NotNil(t, results[1]["data"])
+	assert.Contains(t, recorder.Body.String(), "RATE_LIMITED")
+	assert.NotEmpty(t, recorder.Header().Get("Retry-After"))
+}
+
+func TestLimitRequestBody(t *testing.T) {
+	var received string
+	h := graph.LimitRequestBody(32)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+	}))
+
+	small := `{"query": "{ __typename }"}`
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("POST", "/graphql", strings.NewReader(small)))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, small, received)
+
+	received = ""
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("POST", "/graphql", strings.NewReader(`[`+small+`, `+small+`]`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "request body is too large")
+	assert.Empty(t, received, "the handler is not called")
+}
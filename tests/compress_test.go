@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/middleware"
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveCompressed(h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/graphql", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	recorder := httptest.NewRecorder()
+	middleware.Compress(h).ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestCompress_Negotiation(t *testing.T) {
+	body := `{"data":{"articles":"` + strings.Repeat("lorem ipsum ", 200) + `"}}`
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "2412")
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, body[:100])
+		io.WriteString(w, body[100:])
+	})
+
+	recorder := serveCompressed(h, "gzip, deflate, br")
+	assert.Equal(t, "br", recorder.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", recorder.Header().Get("Vary"))
+	assert.Empty(t, recorder.Header().Get("Content-Length"))
+	assert.Equal(t, `W/"v1"`, recorder.Header().Get("ETag"))
+	decoded, err := io.ReadAll(brotli.NewReader(recorder.Body))
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+	assert.Less(t, recorder.Body.Len(), len(body))
+
+	recorder = serveCompressed(h, "br;q=0, gzip")
+	assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(recorder.Body)
+	require.NoError(t, err)
+	decoded, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+
+	recorder = serveCompressed(h, "br;q=0.5, gzip;q=0.8")
+	assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+
+	for _, accept := range []string{"", "identity", "deflate", "*;q=0"} {
+		recorder = serveCompressed(h, accept)
+		assert.Empty(t, recorder.Header().Get("Content-Encoding"), accept)
+		assert.Equal(t, body, recorder.Body.String(), accept)
+	}
+}
+
+func TestCompress_SkipsSmallAndIncompressibleBodies(t *testing.T) {
+	small := serveCompressed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"data":{}}`)
+	}), "gzip")
+	assert.Equal(t, http.StatusCreated, small.Code)
+	assert.Empty(t, small.Header().Get("Content-Encoding"))
+	assert.Equal(t, `{"data":{}}`, small.Body.String())
+
+	image := serveCompressed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(make([]byte, 4096))
+	}), "gzip")
+	assert.Empty(t, image.Header().Get("Content-Encoding"))
+	assert.Len(t, image.Body.Bytes(), 4096)
+
+	encoded := serveCompressed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(make([]byte, 4096))
+	}), "br")
+	assert.Equal(t, "gzip", encoded.Header().Get("Content-Encoding"))
+	assert.Len(t, encoded.Body.Bytes(), 4096)
+
+	notModified := serveCompressed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}), "gzip")
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Header().Get("Content-Type"))
+	assert.Empty(t, notModified.Header().Get("Content-Encoding"))
+}
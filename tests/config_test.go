@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/database"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearConfigEnv unsets configuration variables of the environment running
+// the tests, e.g. the DB_* variables of the integration tests.
+func clearConfigEnv(t *testing.T) {
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		for _, prefix := range []string{"SERVER_", "DB_", "GRAPHIQL", "GRAPHQL_", "JWT_", "CORS_", "RATE_LIMIT_", "LOG_", "QUERY_CACHE_", "UPLOADS_", "CONFIG_FILE"} {
+			if strings.HasPrefix(name, prefix) {
+				os.Unsetenv(name)
+				t.Cleanup(func() { os.Setenv(name, value) })
+			}
+		}
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, `
+server:
+  addr: ":9000"
+  readTimeout: 5s
+database:
+  host: db.internal
+  port: 6432
+cors:
+  allowedOrigins: ["https://app.example.com"]
+graphql:
+  graphiql: false
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("DB_HOST", "db.env")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com")
+
+	cfg, err := config.Load([]string{"-database.host=db.flag", "-graphql.graphiql", "-server.writeTimeout", "1m"})
+	require.NoError(t, err)
+
+	// The file overrides defaults, the environment the file, flags the environment
+	assert.Equal(t, ":9000", cfg.Server.Addr)
+	assert.Equal(t, 5*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, time.Minute, cfg.Server.WriteTimeout)
+	assert.Equal(t, 6432, cfg.Database.Port)
+	assert.Equal(t, "db.flag", cfg.Database.Host)
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.CORS.AllowedOrigins)
+	assert.True(t, cfg.GraphQL.GraphiQL)
+
+	// Untouched settings keep their defaults
+	assert.Equal(t, config.Default().Database.MaxOpenConns, cfg.Database.MaxOpenConns)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "server:\n  adress: \":9000\"\n"))
+	_, err := config.Load(nil)
+	assert.ErrorContains(t, err, "adress")
+
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DB_PORT", "not-a-port")
+	_, err = config.Load(nil)
+	assert.ErrorContains(t, err, "DB_PORT")
+
+	t.Setenv("DB_PORT", "5432")
+	_, err = config.Load([]string{"-graphql.maxPageSize=5", "-database.maxIdleConns=100"})
+	assert.ErrorContains(t, err, "graphql.maxPageSize")
+	assert.ErrorContains(t, err, "database.maxIdleConns")
+
+	_, err = config.Load([]string{"-database.sslMode=prefer", "-database.sslCert=client.pem"})
+	assert.ErrorContains(t, err, "database.sslMode")
+	assert.ErrorContains(t, err, "database.sslKey")
+
+	// Credentials cannot be allowed for every origin
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	_, err = config.Load(nil)
+	assert.ErrorContains(t, err, "cors.allowCredentials")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com")
+	_, err = config.Load(nil)
+	assert.NoError(t, err)
+
+	t.Setenv("RATE_LIMIT_TRUSTED_PROXIES", "10.0.0.0/8, proxy.internal")
+	_, err = config.Load(nil)
+	assert.ErrorContains(t, err, "rateLimit.trustedProxies")
+	t.Setenv("RATE_LIMIT_TRUSTED_PROXIES", "10.0.0.0/8")
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	_, err = config.Load(nil)
+	assert.ErrorContains(t, err, "logging.level")
+}
+
+func TestLoadConfig_ExampleMatchesDefaults(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("CONFIG_FILE", "../config.example.yaml")
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, config.Default(), cfg)
+}
+
+func TestDatabaseDSN(t *testing.T) {
+	cfg := config.Default().Database
+	cfg.Password = "it's secret"
+
+	dsn, err := database.DSN(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, `dbname=articles_db host=localhost password='it\'s secret' port=5432 sslmode=disable statement_timeout=10000 user=postgres`, dsn)
+	_, err = pq.NewConnector(dsn)
+	assert.NoError(t, err)
+
+	cfg.SSLMode = "verify-full"
+	cfg.SSLRootCert = "/etc/ssl/db-ca.pem"
+	dsn, err = database.DSN(cfg)
+	require.NoError(t, err)
+	assert.Contains(t, dsn, "sslmode=verify-full sslrootcert=/etc/ssl/db-ca.pem")
+
+	// The URL replaces the connection fields, explicit TLS settings override its parameters
+	cfg.URL = "postgres://app:pw@db.example.com:6432/articles?sslmode=require&application_name=api"
+	cfg.SSLCert = "/etc/ssl/client.pem"
+	cfg.SSLKey = "/etc/ssl/client.key"
+	dsn, err = database.DSN(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://app:pw@db.example.com:6432/articles?application_name=api"+
+		"&sslcert=%2Fetc%2Fssl%2Fclient.pem&sslkey=%2Fetc%2Fssl%2Fclient.key"+
+		"&sslmode=verify-full&sslrootcert=%2Fetc%2Fssl%2Fdb-ca.pem&statement_timeout=10000", dsn)
+
+	cfg.SSLMode = ""
+	cfg.SSLRootCert, cfg.SSLCert, cfg.SSLKey = "", "", ""
+	dsn, err = database.DSN(cfg)
+	require.NoError(t, err)
+	assert.Contains(t, dsn, "sslmode=require")
+
+	cfg.StatementTimeout = 0
+	dsn, err = database.DSN(cfg)
+	require.NoError(t, err)
+	assert.NotContains(t, dsn, "statement_timeout")
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/middleware"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORSConfig_AllowsOrigin(t *testing.T) {
+	cfg := middleware.CORSConfig{AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"}}
+
+	assert.True(t, cfg.AllowsOrigin("https://app.example.com"))
+	assert.True(t, cfg.AllowsOrigin("https://a.b.example.org"))
+	assert.False(t, cfg.AllowsOrigin("https://example.org"))
+	assert.False(t, cfg.AllowsOrigin("http://app.example.org"))
+	assert.False(t, cfg.AllowsOrigin("https://evil.com/.example.org"))
+	assert.False(t, cfg.AllowsOrigin("https://other.example.com"))
+
+	assert.True(t, middleware.CORSConfig{AllowedOrigins: []string{"*"}}.AllowsOrigin("https://any.where"))
+}
+
+func TestCORS(t *testing.T) {
+	handler := middleware.CORS(middleware.CORSConfig{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"Retry-After"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	serve := func(method, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/graphql", nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	preflight := serve(http.MethodOptions, "https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, preflight.Code)
+	assert.Equal(t, "https://app.example.com", preflight.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", preflight.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET, POST", preflight.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type, Authorization", preflight.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", preflight.Header().Get("Access-Control-Max-Age"))
+
+	actual := serve(http.MethodPost, "https://app.example.com")
+	assert.Equal(t, http.StatusTeapot, actual.Code)
+	assert.Equal(t, "https://app.example.com", actual.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Retry-After", actual.Header().Get("Access-Control-Expose-Headers"))
+
+	// Other origins get no CORS headers
+	denied := serve(http.MethodOptions, "https://evil.com")
+	assert.Equal(t, http.StatusNoContent, denied.Code)
+	assert.Empty(t, denied.Header().Get("Access-Control-Allow-Origin"))
+
+	denied = serve(http.MethodPost, "https://evil.com")
+	assert.Equal(t, http.StatusTeapot, denied.Code)
+	assert.Empty(t, denied.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestWebSocketHandler_ChecksOrigin(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+
+	wsHandler := graph.NewWebSocketHandler(&schema, http.NotFoundHandler())
+	wsHandler.CheckOrigin = middleware.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}.CheckOrigin
+	server := httptest.NewServer(wsHandler)
+	defer server.Close()
+
+	dial := func(origin string) (*http.Response, error) {
+		dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+		conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Origin": {origin}})
+		if err == nil {
+			conn.Close()
+		}
+		return resp, err
+	}
+
+	_, err = dial("https://app.example.com")
+	assert.NoError(t, err)
+
+	resp, err := dial("https://evil.com")
+	assert.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/database"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth_Live(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	health.Live().ServeHTTP(recorder, httptest.NewRequest("GET", "/livez", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, recorder.Body.String())
+}
+
+func TestHealth_Ready(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	probe := func(checks map[string]health.Check) (int, health.Report) {
+		recorder := httptest.NewRecorder()
+		health.Ready(checks, 50*time.Millisecond).ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+
+		var report health.Report
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+		return recorder.Code, report
+	}
+
+	code, report := probe(map[string]health.Check{"database": ok, "migrations": ok})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", report.Status)
+	assert.Equal(t, "ok", report.Checks["migrations"].Status)
+
+	code, report = probe(map[string]health.Check{"database": failing, "migrations": ok, "cache": slow})
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", report.Status)
+	assert.Equal(t, "unavailable", report.Checks["database"].Status)
+	assert.Equal(t, "ok", report.Checks["migrations"].Status)
+	assert.Equal(t, "unavailable", report.Checks["cache"].Status)
+}
+
+func TestNewConnection_RetriesUntilTimeout(t *testing.T) {
+	cfg := config.Default().Database
+	cfg.Host = "127.0.0.1"
+	cfg.Port = 1 // nothing listens here
+	cfg.ConnectTimeout = time.Second
+
+	start := time.Now()
+	_, err := database.NewConnection(cfg)
+	require.Error(t, err)
+
+	// Attempts after 0s and 0.5s; the next one would be after the timeout
+	assert.Contains(t, err.Error(), "after 2 attempt(s)")
+	assert.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
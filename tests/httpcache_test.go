@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/graphql-go/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// persistedQueries writes documents to a persisted queries file and loads
+// it, returning the hashes of the documents.
+func persistedQueries(t *testing.T, documents ...string) (graph.PersistedQueries, []string) {
+	hashes := make([]string, len(documents))
+	byHash := make(map[string]string, len(documents))
+	for i, document := range documents {
+		sum := sha256.Sum256([]byte(document))
+		hashes[i] = hex.EncodeToString(sum[:])
+		byHash[hashes[i]] = document
+	}
+	data, err := json.Marshal(byHash)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "persisted.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	queries, err := graph.LoadPersistedQueries(path)
+	require.NoError(t, err)
+	return queries, hashes
+}
+
+// persistedQueryURL is the GET URL of the persisted query with hash.
+func persistedQueryURL(hash string) string {
+	return "/graphql?extensions=" + url.QueryEscape(`{"persistedQuery":{"version":1,"sha256Hash":"`+hash+`"}}`)
+}
+
+func TestLoadPersistedQueries_RejectsWrongHashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "persisted.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"0123": "{ __typename }"}`), 0o600))
+	_, err := graph.LoadPersistedQueries(path)
+	assert.ErrorContains(t, err, "does not match")
+}
+
+func TestCacheQueries(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+	graphqlHandler := handler.New(&handler.Config{Schema: &schema, FormatErrorFn: apperr.Format})
+	queries, hashes := persistedQueries(t, `{ __typename }`, `query Named { __typename }`, `{ apiKeys { id } }`)
+	typename, named, apiKeys := hashes[0], hashes[1], hashes[2]
+
+	executed := 0
+	modifiedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := queries.Middleware(graph.CacheQueries(func(context.Context) (time.Time, error) {
+		return modifiedAt, nil
+	}, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		executed++
+		graphqlHandler.ServeHTTP(w, r)
+	})))
+
+	get := func(target, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	first := get(persistedQueryURL(typename), "")
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.JSONEq(t, `{"data":{"__typename":"Query"}}`, first.Body.String())
+	etag := first.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`), etag)
+	assert.Equal(t, "public, no-cache", first.Header().Get("Cache-Control"))
+	assert.Equal(t, "Authorization", first.Header().Get("Vary"))
+	assert.Equal(t, 1, executed)
+
+	// Revalidation is answered without executing the query
+	revalidated := get(persistedQueryURL(typename), etag)
+	assert.Equal(t, http.StatusNotModified, revalidated.Code)
+	assert.Empty(t, revalidated.Body.String())
+	assert.Equal(t, etag, revalidated.Header().Get("ETag"))
+	assert.Equal(t, 1, executed)
+
+	// A wildcard does not stand for the response held by the client
+	wildcard := get(persistedQueryURL(typename), "*")
+	assert.Equal(t, http.StatusOK, wildcard.Code)
+	assert.Equal(t, 2, executed)
+
+	// Other queries and later changes get other tags
+	assert.NotEqual(t, etag, get(persistedQueryURL(named), etag).Header().Get("ETag"))
+	modifiedAt = modifiedAt.Add(time.Second)
+	changed := get(persistedQueryURL(typename), etag)
+	assert.Equal(t, http.StatusOK, changed.Code)
+	assert.NotEqual(t, etag, changed.Header().Get("ETag"))
+
+	// Failed queries are not cacheable
+	failed := get(persistedQueryURL(apiKeys), "")
+	assert.Contains(t, failed.Body.String(), "UNAUTHENTICATED")
+	assert.Empty(t, failed.Header().Get("ETag"))
+	assert.Empty(t, failed.Header().Get("Cache-Control"))
+
+	// Neither are queries sent as documents
+	document := get("/graphql?query="+url.QueryEscape(`{ __typename }`), "")
+	assert.Equal(t, http.StatusOK, document.Code)
+	assert.Empty(t, document.Header().Get("ETag"))
+	assert.Empty(t, document.Header().Get("Cache-Control"))
+
+	// Unknown hashes are rejected
+	unknown := get(persistedQueryURL(strings.Repeat("0", 64)), "")
+	assert.Equal(t, http.StatusBadRequest, unknown.Code)
+	assert.Contains(t, unknown.Body.String(), "NOT_FOUND")
+
+	// Neither are the responses of authenticated clients
+	req := httptest.NewRequest("GET", persistedQueryURL(typename), nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "alice", Roles: []auth.Role{auth.RoleReader}}))
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"data":{"__typename":"Query"}}`, recorder.Body.String())
+	assert.Empty(t, recorder.Header().Get("ETag"))
+	assert.Equal(t, "Authorization", recorder.Header().Get("Vary"))
+}
+
+func TestCacheQueries_MaxAge(t *testing.T) {
+	queries, hashes := persistedQueries(t, `{articles{id}}`, `mutation { deleteArticle(id: "1") { deletedArticleId } }`)
+	h := queries.Middleware(graph.CacheQueries(func(context.Context) (time.Time, error) {
+		return time.Unix(0, 0), nil
+	}, 5*time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"articles":null}}`))
+	})))
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("GET", persistedQueryURL(hashes[0]), nil))
+	assert.Equal(t, "public, max-age=300", recorder.Header().Get("Cache-Control"))
+
+	// Mutations are never cached, not even over GET
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("GET", persistedQueryURL(hashes[1]), nil))
+	assert.Empty(t, recorder.Header().Get("Cache-Control"))
+
+	// Neither are queries whose variables are too long to hash cheaply
+	variables := `{"padding":"` + strings.Repeat(" ", 5000) + `"}`
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("GET", persistedQueryURL(hashes[0])+"&variables="+url.QueryEscape(variables), nil))
+	assert.Empty(t, recorder.Header().Get("ETag"))
+	assert.Empty(t, recorder.Header().Get("Cache-Control"))
+}
@@ -2,16 +2,24 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/cache"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
 	"github.com/StillLearnSVN/go-graphql-articles/internal/database"
 	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/storage"
 	"github.com/graphql-go/handler"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -21,8 +29,12 @@ import (
 
 type IntegrationTestSuite struct {
 	suite.Suite
-	db      *database.DB
-	handler http.Handler
+	db         *database.DB
+	queryCache *graph.QueryCache
+	images     *storage.DiskStore
+	// imagesHandler serves the images like the server does under /images
+	imagesHandler http.Handler
+	handler       http.Handler
 }
 
 func (suite *IntegrationTestSuite) SetupSuite() {
@@ -47,21 +59,33 @@ func (suite *IntegrationTestSuite) SetupSuite() {
 	suite.Require().NoError(err)
 
 
-	suite.db, err = database.NewConnection()
-	suite.Require().NoError(err, "Failed to connect to the test database. Ensure database.NewConnection() reads env vars.")
+	cfg, err := config.Load(nil)
+	suite.Require().NoError(err)
+
+	suite.db, err = database.NewConnection(cfg.Database)
+	suite.Require().NoError(err, "Failed to connect to the test database. Ensure the DB_* variables are set in .env_test.")
 
 	err = suite.db.RunMigrations()
 	suite.Require().NoError(err)
 
 	// Setup GraphQL handler
-	resolver := graph.NewResolver(suite.db)
+	resolver := graph.NewResolver(suite.db, cfg.GraphQL)
 	schema, err := graph.CreateSchema(resolver)
 	suite.Require().NoError(err)
 
-	suite.handler = handler.New(&handler.Config{
-		Schema: &schema,
-		Pretty: true,
-	})
+	suite.images, err = storage.NewDiskStore(suite.T().TempDir(), "/images")
+	suite.Require().NoError(err)
+	resolver.SetImageStore(suite.images)
+	suite.imagesHandler = http.StripPrefix("/images", resolver.ImageAccess(suite.images.Handler()))
+
+	// Cache listings like the server does, so that stale results show up
+	suite.queryCache = graph.NewQueryCache(cache.NewMemoryStore(100), time.Minute)
+	resolver.SetQueryCache(suite.queryCache)
+	suite.handler = suite.queryCache.Middleware(handler.New(&handler.Config{
+		Schema:        &schema,
+		Pretty:        true,
+		FormatErrorFn: apperr.Format,
+	}))
 }
 
 func (suite *IntegrationTestSuite) TearDownSuite() {
@@ -74,6 +98,9 @@ func (suite *IntegrationTestSuite) SetupTest() {
 	suite.Require().NoError(err)
 	_, err = suite.db.Exec("DELETE FROM authors")
 	suite.Require().NoError(err)
+	_, err = suite.db.Exec("DELETE FROM api_keys")
+	suite.Require().NoError(err)
+	suite.queryCache.Invalidate(context.Background())
 }
 
 func (suite *IntegrationTestSuite) TestCreateArticle() {
@@ -82,25 +109,34 @@ func (suite *IntegrationTestSuite) TestCreateArticle() {
             createArticle(input: {
                 title: "Test Article"
                 body: "This is a test article body"
-                authorName: "John Doe"
             }) {
-                id
-                title
-                body
-                author {
+                article {
                     id
-                    name
+                    title
+                    body
+                    author {
+                        id
+                        name
+                    }
+                    createdAt
+                    status
+                    publishedAt
+                }
+                userErrors {
+                    field
+                    message
                 }
-                createdAt
             }
         }
     `
 
-	response := suite.executeGraphQL(mutation)
+	response := suite.executeGraphQLAs("John Doe", mutation)
 
 	assert.NotNil(suite.T(), response["data"])
 	data := response["data"].(map[string]interface{})
-	article := data["createArticle"].(map[string]interface{})
+	payload := data["createArticle"].(map[string]interface{})
+	assert.Empty(suite.T(), payload["userErrors"])
+	article := payload["article"].(map[string]interface{})
 
 	assert.NotEmpty(suite.T(), article["id"])
 	assert.Equal(suite.T(), "Test Article", article["title"])
@@ -109,6 +145,8 @@ func (suite *IntegrationTestSuite) TestCreateArticle() {
 	author := article["author"].(map[string]interface{})
 	assert.Equal(suite.T(), "John Doe", author["name"])
 	assert.NotEmpty(suite.T(), article["createdAt"])
+	assert.Equal(suite.T(), "DRAFT", article["status"])
+	assert.Nil(suite.T(), article["publishedAt"])
 }
 
 func (suite *IntegrationTestSuite) TestCreateArticle_ValidationError() {
@@ -117,19 +155,54 @@ func (suite *IntegrationTestSuite) TestCreateArticle_ValidationError() {
             createArticle(input: {
                 title: ""
                 body: "This is a test article body"
-                authorName: "John Doe"
             }) {
-                id
-                title
+                article {
+                    id
+                    title
+                }
+                userErrors {
+                    field
+                    message
+                    code
+                }
             }
         }
     `
 
-	response := suite.executeGraphQL(mutation)
+	response := suite.executeGraphQLAs("John Doe", mutation)
 
-	assert.NotNil(suite.T(), response["errors"])
-	errors := response["errors"].([]interface{})
-	assert.Greater(suite.T(), len(errors), 0)
+	assert.Nil(suite.T(), response["errors"])
+	data := response["data"].(map[string]interface{})
+	payload := data["createArticle"].(map[string]interface{})
+	assert.Nil(suite.T(), payload["article"])
+
+	userErrors := payload["userErrors"].([]interface{})
+	assert.Equal(suite.T(), 1, len(userErrors))
+
+	userError := userErrors[0].(map[string]interface{})
+	assert.Equal(suite.T(), "BAD_USER_INPUT", userError["code"])
+	assert.Equal(suite.T(), []interface{}{"input", "title"}, userError["field"])
+	assert.Equal(suite.T(), "title cannot be empty", userError["message"])
+}
+
+func (suite *IntegrationTestSuite) TestCreateArticle_AuthorsKeyedOnSubject() {
+	mutation := `mutation { createArticle(input: { title: "Title", body: "Body" }) { article { author { id } } } }`
+	authorID := func(principal *auth.Principal) interface{} {
+		response := suite.executeGraphQLWith(principal, mutation)
+		suite.Require().Nil(response["errors"])
+		payload := response["data"].(map[string]interface{})["createArticle"].(map[string]interface{})
+		return payload["article"].(map[string]interface{})["author"].(map[string]interface{})["id"]
+	}
+
+	// Users sharing a display name are different authors, each keeping their email
+	first := authorID(&auth.Principal{Subject: "user-1", Name: "Sam", Email: "sam@one.example", Roles: []auth.Role{auth.RoleAuthor}})
+	second := authorID(&auth.Principal{Subject: "user-2", Name: "Sam", Email: "sam@two.example", Roles: []auth.Role{auth.RoleAuthor}})
+	assert.NotEqual(suite.T(), first, second)
+	assert.Equal(suite.T(), first, authorID(&auth.Principal{Subject: "user-1", Name: "Sam", Roles: []auth.Role{auth.RoleAuthor}}))
+
+	var email string
+	suite.Require().NoError(suite.db.QueryRow("SELECT email FROM authors WHERE id = $1", first).Scan(&email))
+	assert.Equal(suite.T(), "sam@one.example", email)
 }
 
 func (suite *IntegrationTestSuite) TestGetArticles() {
@@ -216,6 +289,10 @@ func (suite *IntegrationTestSuite) TestGetArticles_WithAuthorFilter() {
 		author := node["author"].(map[string]interface{})
 		assert.Equal(suite.T(), "Alice", author["name"])
 	}
+
+	// Wildcards in the filter match themselves
+	wildcard := suite.executeGraphQL(`{ articles(author: "A%e") { totalCount } }`)
+	assert.Equal(suite.T(), float64(0), wildcard["data"].(map[string]interface{})["articles"].(map[string]interface{})["totalCount"])
 }
 
 func (suite *IntegrationTestSuite) TestGetArticles_WithTextSearch() {
@@ -248,6 +325,27 @@ func (suite *IntegrationTestSuite) TestGetArticles_WithTextSearch() {
 	assert.Equal(suite.T(), float64(2), articles["totalCount"])
 }
 
+func (suite *IntegrationTestSuite) TestGetArticles_WithCreatedRange() {
+	suite.createTestArticle("Recent Article", "Content", "Alice")
+
+	query := `
+        query {
+            after: articles(createdAfter: "2000-01-01T07:00:00+07:00") {
+                totalCount
+            }
+            before: articles(createdBefore: "2000-01-01T00:00:00Z") {
+                totalCount
+            }
+        }
+    `
+
+	response := suite.executeGraphQL(query)
+
+	data := response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), float64(1), data["after"].(map[string]interface{})["totalCount"])
+	assert.Equal(suite.T(), float64(0), data["before"].(map[string]interface{})["totalCount"])
+}
+
 func (suite *IntegrationTestSuite) TestGetArticles_Pagination() {
 	// Create test articles
 	for i := 1; i <= 5; i++ {
@@ -309,23 +407,403 @@ func (suite *IntegrationTestSuite) TestGetArticles_Pagination() {
 	assert.Equal(suite.T(), 2, len(nextEdges))
 }
 
-func (suite *IntegrationTestSuite) createTestArticle(title, body, authorName string) {
+func (suite *IntegrationTestSuite) TestUpdateArticle_OnlyOwnUnlessEditor() {
+	id := suite.createTestArticle("Original", "Content", "Alice")
+
+	mutation := fmt.Sprintf(`
+        mutation {
+            updateArticle(id: "%s", input: { title: "Edited" }) {
+                article {
+                    title
+                    body
+                }
+                userErrors {
+                    message
+                }
+            }
+        }
+    `, id)
+
+	// Other authors are denied, even when they go by the same name
+	impostor := &auth.Principal{Subject: "user-Mallory", Name: "Alice", Roles: []auth.Role{auth.RoleAuthor}}
+	for _, response := range []map[string]interface{}{
+		suite.executeGraphQLAs("Bob", mutation),
+		suite.executeGraphQLWith(impostor, mutation),
+		suite.executeGraphQLWith(impostor, fmt.Sprintf(`mutation { deleteArticle(id: "%s") { deletedArticleId } }`, id)),
+	} {
+		errors := response["errors"].([]interface{})
+		extensions := errors[0].(map[string]interface{})["extensions"].(map[string]interface{})
+		assert.Equal(suite.T(), "FORBIDDEN", extensions["code"])
+	}
+
+	// The author and editors may edit it
+	for _, principal := range []*auth.Principal{
+		{Subject: "user-Alice", Name: "Alice", Roles: []auth.Role{auth.RoleAuthor}},
+		{Subject: "user-Eve", Name: "Eve", Roles: []auth.Role{auth.RoleEditor}},
+	} {
+		response := suite.executeGraphQLWith(principal, mutation)
+		assert.Nil(suite.T(), response["errors"])
+
+		payload := response["data"].(map[string]interface{})["updateArticle"].(map[string]interface{})
+		article := payload["article"].(map[string]interface{})
+		assert.Equal(suite.T(), "Edited", article["title"])
+		assert.Equal(suite.T(), "Content", article["body"])
+	}
+}
+
+func (suite *IntegrationTestSuite) TestAuthorEmail_OnlyVisibleToEditors() {
+	created := suite.executeGraphQLWith(&auth.Principal{
+		Subject: "user-Alice",
+		Name:    "Alice",
+		Email:   "alice@example.com",
+		Roles:   []auth.Role{auth.RoleAuthor},
+	}, `mutation { createArticle(input: { title: "Title", body: "Body" }) { article { id } } }`)
+	payload := created["data"].(map[string]interface{})["createArticle"].(map[string]interface{})
+	suite.publishTestArticle(payload["article"].(map[string]interface{})["id"].(string))
+
+	query := `
+        query {
+            articles(first: 1) {
+                edges {
+                    node {
+                        author {
+                            name
+                            email
+                        }
+                    }
+                }
+            }
+        }
+    `
+
+	authorOf := func(response map[string]interface{}) map[string]interface{} {
+		articles := response["data"].(map[string]interface{})["articles"].(map[string]interface{})
+		node := articles["edges"].([]interface{})[0].(map[string]interface{})["node"].(map[string]interface{})
+		return node["author"].(map[string]interface{})
+	}
+
+	response := suite.executeGraphQL(query)
+	assert.Nil(suite.T(), authorOf(response)["email"])
+	assert.NotNil(suite.T(), response["errors"])
+
+	response = suite.executeGraphQLWith(&auth.Principal{Subject: "user-Eve", Name: "Eve", Roles: []auth.Role{auth.RoleEditor}}, query)
+	assert.Nil(suite.T(), response["errors"])
+	assert.Equal(suite.T(), "alice@example.com", authorOf(response)["email"])
+}
+
+func (suite *IntegrationTestSuite) TestAPIKeys_IssueAndRevoke() {
+	admin := &auth.Principal{Subject: "user-root", Name: "root", Roles: []auth.Role{auth.RoleAdmin}}
+
+	response := suite.executeGraphQLWith(admin, `
+        mutation {
+            createApiKey(input: { name: "ingest", scopes: [READ, WRITE] }) {
+                apiKey {
+                    id
+                    name
+                    prefix
+                    scopes
+                    lastUsedAt
+                }
+                key
+                userErrors {
+                    message
+                }
+            }
+        }
+    `)
+	assert.Nil(suite.T(), response["errors"])
+	payload := response["data"].(map[string]interface{})["createApiKey"].(map[string]interface{})
+	apiKey := payload["apiKey"].(map[string]interface{})
+	key := payload["key"].(string)
+
+	assert.Equal(suite.T(), "ingest", apiKey["name"])
+	assert.Equal(suite.T(), []interface{}{"READ", "WRITE"}, apiKey["scopes"])
+	assert.Nil(suite.T(), apiKey["lastUsedAt"])
+	assert.True(suite.T(), strings.HasPrefix(key, apiKey["prefix"].(string)))
+
+	// Using the key records when it was last used
+	stored, err := suite.db.AuthenticateAPIKey(context.Background(), auth.HashAPIKey(key))
+	suite.Require().NoError(err)
+	suite.Require().NotNil(stored.LastUsedAt)
+
+	// but does not write it again on every request
+	again, err := suite.db.AuthenticateAPIKey(context.Background(), auth.HashAPIKey(key))
+	suite.Require().NoError(err)
+	assert.True(suite.T(), stored.LastUsedAt.Equal(*again.LastUsedAt))
+
+	// Authors may not issue keys
+	response = suite.executeGraphQLAs("Alice", `mutation { createApiKey(input: { name: "x", scopes: [READ] }) { key } }`)
+	errors := response["errors"].([]interface{})
+	extensions := errors[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	assert.Equal(suite.T(), "FORBIDDEN", extensions["code"])
+
+	response = suite.executeGraphQLWith(admin, fmt.Sprintf(`
+        mutation {
+            revokeApiKey(id: "%s") {
+                apiKey {
+                    revokedAt
+                }
+            }
+        }
+    `, apiKey["id"]))
+	assert.Nil(suite.T(), response["errors"])
+	payload = response["data"].(map[string]interface{})["revokeApiKey"].(map[string]interface{})
+	assert.NotNil(suite.T(), payload["apiKey"].(map[string]interface{})["revokedAt"])
+
+	_, err = suite.db.AuthenticateAPIKey(context.Background(), auth.HashAPIKey(key))
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *IntegrationTestSuite) TestArticles_CanceledRequestIsReported() {
+	suite.createTestArticle("Title", "Body", "Alice")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ articles { totalCount } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	suite.handler.ServeHTTP(recorder, req.WithContext(ctx))
+
+	var response map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &response))
+
+	errs := response["errors"].([]interface{})
+	suite.Require().NotEmpty(errs)
+	extensions := errs[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	assert.Equal(suite.T(), "CANCELED", extensions["code"])
+}
+
+func (suite *IntegrationTestSuite) TestContentModifiedAt_AdvancesOnEveryChange() {
+	ctx := context.Background()
+	before, err := suite.db.ContentModifiedAt(ctx)
+	suite.Require().NoError(err)
+
+	id := suite.createTestArticle("Title", "Body", "Alice")
+	created, err := suite.db.ContentModifiedAt(ctx)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), created.After(before))
+
+	// Deletions leave no row behind but still count as a change
+	_, err = suite.db.ExecContext(ctx, "DELETE FROM articles WHERE id = $1", id)
+	suite.Require().NoError(err)
+	deleted, err := suite.db.ContentModifiedAt(ctx)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), deleted.After(created))
+}
+
+func (suite *IntegrationTestSuite) TestQueryCache_InvalidatedByMutations() {
+	query := `{ articles(first: 10) { totalCount edges { node { title } } } }`
+	totalCount := func() float64 {
+		response := suite.executeGraphQL(query)
+		return response["data"].(map[string]interface{})["articles"].(map[string]interface{})["totalCount"].(float64)
+	}
+
+	assert.Equal(suite.T(), 0.0, totalCount())
+	id := suite.createTestArticle("Title", "Body", "Alice")
+	assert.Equal(suite.T(), 1.0, totalCount())
+
+	response := suite.executeGraphQLWith(&auth.Principal{Subject: "admin", Roles: []auth.Role{auth.RoleAdmin}},
+		fmt.Sprintf(`mutation { deleteArticle(id: "%s") { deletedArticleId } }`, id))
+	suite.Require().Nil(response["errors"])
+	assert.Equal(suite.T(), 0.0, totalCount())
+}
+
+func (suite *IntegrationTestSuite) TestUploadImage() {
+	id := suite.createTestArticle("Title", "Body", "Alice")
+
+	mutation := `mutation ($id: ID!, $file: Upload!) {
+        uploadImage(articleId: $id, file: $file) { image { id url contentType size } userErrors { message } }
+    }`
+	operations, err := json.Marshal(map[string]interface{}{
+		"query":     mutation,
+		"variables": map[string]interface{}{"id": id, "file": nil},
+	})
+	suite.Require().NoError(err)
+	content := pngImage(suite.T())
+	req := multipartRequest(suite.T(), string(operations), `{"0": ["variables.file"]}`, map[string][]byte{"0": content})
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{
+		Subject: "user-Alice", Name: "Alice", Roles: []auth.Role{auth.RoleAuthor},
+	}))
+	recorder := httptest.NewRecorder()
+	graph.MultipartRequests(10<<20, 1<<20, nil)(suite.handler).ServeHTTP(recorder, req)
+
+	var response map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &response))
+	suite.Require().Nil(response["errors"])
+	payload := response["data"].(map[string]interface{})["uploadImage"].(map[string]interface{})
+	suite.Require().Empty(payload["userErrors"])
+	image := payload["image"].(map[string]interface{})
+	assert.Equal(suite.T(), "image/png", image["contentType"])
+	assert.Equal(suite.T(), float64(len(content)), image["size"])
+	url := image["url"].(string)
+	assert.True(suite.T(), strings.HasPrefix(url, "/images/") && strings.HasSuffix(url, ".png"), url)
+
+	// The image is listed on its article only and served from its URL
+	suite.createTestArticle("Other", "Body", "Alice")
+	listed := suite.executeGraphQL(`{ articles { edges { node { title images { url } } } } }`)
+	edges := listed["data"].(map[string]interface{})["articles"].(map[string]interface{})["edges"].([]interface{})
+	suite.Require().Len(edges, 2)
+	for _, edge := range edges {
+		node := edge.(map[string]interface{})["node"].(map[string]interface{})
+		if node["title"] == "Title" {
+			assert.Equal(suite.T(), []interface{}{map[string]interface{}{"url": url}}, node["images"])
+		} else {
+			assert.Empty(suite.T(), node["images"])
+		}
+	}
+
+	served := httptest.NewRecorder()
+	suite.imagesHandler.ServeHTTP(served, httptest.NewRequest("GET", url, nil))
+	assert.Equal(suite.T(), http.StatusOK, served.Code)
+	assert.Equal(suite.T(), content, served.Body.Bytes())
+
+	// Images of unpublished articles are only served to those who may edit them
+	editor := &auth.Principal{Subject: "user-Eve", Name: "Eve", Roles: []auth.Role{auth.RoleEditor}}
+	unpublished := suite.executeGraphQLWith(editor, fmt.Sprintf(`mutation { updateArticle(id: "%s", input: { status: DRAFT }) { article { status } } }`, id))
+	suite.Require().Nil(unpublished["errors"])
+	served = httptest.NewRecorder()
+	suite.imagesHandler.ServeHTTP(served, httptest.NewRequest("GET", url, nil))
+	assert.Equal(suite.T(), http.StatusNotFound, served.Code)
+	authorRequest := httptest.NewRequest("GET", url, nil)
+	authorRequest = authorRequest.WithContext(auth.WithPrincipal(authorRequest.Context(), &auth.Principal{
+		Subject: "user-Alice", Name: "Alice", Roles: []auth.Role{auth.RoleAuthor},
+	}))
+	served = httptest.NewRecorder()
+	suite.imagesHandler.ServeHTTP(served, authorRequest)
+	assert.Equal(suite.T(), http.StatusOK, served.Code)
+	assert.Equal(suite.T(), "private, no-store", served.Header().Get("Cache-Control"))
+
+	// Deleting the article deletes its images
+	deleted := suite.executeGraphQLAs("Alice", fmt.Sprintf(`mutation { deleteArticle(id: "%s") { deletedArticleId } }`, id))
+	suite.Require().Nil(deleted["errors"])
+	served = httptest.NewRecorder()
+	suite.imagesHandler.ServeHTTP(served, httptest.NewRequest("GET", url, nil))
+	assert.Equal(suite.T(), http.StatusNotFound, served.Code)
+}
+
+func (suite *IntegrationTestSuite) TestPublishingWorkflow() {
+	editor := &auth.Principal{Subject: "user-Eve", Name: "Eve", Roles: []auth.Role{auth.RoleEditor}}
+	impostor := &auth.Principal{Subject: "user-Mallory", Name: "Alice", Roles: []auth.Role{auth.RoleAuthor}}
+	created := suite.executeGraphQLAs("Alice", `mutation { createArticle(input: { title: "Draft", body: "Body" }) { article { id } } }`)
+	id := created["data"].(map[string]interface{})["createArticle"].(map[string]interface{})["article"].(map[string]interface{})["id"].(string)
+
+	query := `{ articles { totalCount edges { node { status publishedAt } } } }`
+	listed := func(response map[string]interface{}) []interface{} {
+		suite.Require().Nil(response["errors"])
+		return response["data"].(map[string]interface{})["articles"].(map[string]interface{})["edges"].([]interface{})
+	}
+	statusOf := func(edges []interface{}) interface{} {
+		return edges[0].(map[string]interface{})["node"].(map[string]interface{})["status"]
+	}
+
+	// Drafts are only listed for their author and editors
+	assert.Empty(suite.T(), listed(suite.executeGraphQL(query)))
+	assert.Empty(suite.T(), listed(suite.executeGraphQLAs("Bob", query)))
+	assert.Empty(suite.T(), listed(suite.executeGraphQLWith(impostor, query)))
+	assert.Equal(suite.T(), "DRAFT", statusOf(listed(suite.executeGraphQLAs("Alice", query))))
+	assert.Len(suite.T(), listed(suite.executeGraphQLWith(editor, `{ articles(status: DRAFT) { edges { node { id } } } }`)), 1)
+
+	// The author submits it for review, an editor publishes it
+	denied := suite.executeGraphQLWith(impostor, fmt.Sprintf(`mutation { submitArticle(id: "%s") { article { status } } }`, id))
+	errors := denied["errors"].([]interface{})
+	assert.Equal(suite.T(), "FORBIDDEN", errors[0].(map[string]interface{})["extensions"].(map[string]interface{})["code"])
+	submitted := suite.executeGraphQLAs("Alice", fmt.Sprintf(`mutation { submitArticle(id: "%s") { article { status } } }`, id))
+	suite.Require().Nil(submitted["errors"])
+	assert.Equal(suite.T(), "IN_REVIEW", submitted["data"].(map[string]interface{})["submitArticle"].(map[string]interface{})["article"].(map[string]interface{})["status"])
+	assert.Equal(suite.T(), "IN_REVIEW", statusOf(listed(suite.executeGraphQLWith(editor, `{ articles(status: IN_REVIEW) { edges { node { status } } } }`))))
+
+	// Authors cannot publish it themselves
+	denied = suite.executeGraphQLAs("Alice", fmt.Sprintf(`mutation { publishArticle(id: "%s") { article { status } } }`, id))
+	errors = denied["errors"].([]interface{})
+	assert.Equal(suite.T(), "FORBIDDEN", errors[0].(map[string]interface{})["extensions"].(map[string]interface{})["code"])
+	published := suite.executeGraphQLWith(editor, fmt.Sprintf(`mutation { publishArticle(id: "%s") { article { status publishedAt } } }`, id))
+	suite.Require().Nil(published["errors"])
+	article := published["data"].(map[string]interface{})["publishArticle"].(map[string]interface{})["article"].(map[string]interface{})
+	assert.Equal(suite.T(), "PUBLISHED", article["status"])
+	assert.NotNil(suite.T(), article["publishedAt"])
+
+	edges := listed(suite.executeGraphQL(query))
+	suite.Require().Len(edges, 1)
+	assert.Equal(suite.T(), "PUBLISHED", statusOf(edges))
+
+	// Only editors can take it back
+	archived := suite.executeGraphQLAs("Alice", fmt.Sprintf(`mutation { updateArticle(id: "%s", input: { status: ARCHIVED }) { article { status } } }`, id))
+	errors = archived["errors"].([]interface{})
+	assert.Equal(suite.T(), "FORBIDDEN", errors[0].(map[string]interface{})["extensions"].(map[string]interface{})["code"])
+
+	unpublished := suite.executeGraphQLWith(editor, fmt.Sprintf(`mutation { unpublishArticle(id: "%s") { article { status publishedAt } } }`, id))
+	suite.Require().Nil(unpublished["errors"])
+	article = unpublished["data"].(map[string]interface{})["unpublishArticle"].(map[string]interface{})["article"].(map[string]interface{})
+	assert.Equal(suite.T(), "DRAFT", article["status"])
+	assert.Nil(suite.T(), article["publishedAt"])
+	assert.Empty(suite.T(), listed(suite.executeGraphQL(query)))
+}
+
+func (suite *IntegrationTestSuite) TestPublishersPublishTheirOwnArticles() {
+	publisher := &auth.Principal{Subject: "apikey:7", Name: "ingest", Roles: []auth.Role{auth.RolePublisher}}
+	created := suite.executeGraphQLWith(publisher, `mutation { createArticle(input: { title: "Ingested", body: "Body" }) { article { id } } }`)
+	suite.Require().Nil(created["errors"])
+	id := created["data"].(map[string]interface{})["createArticle"].(map[string]interface{})["article"].(map[string]interface{})["id"].(string)
+
+	published := suite.executeGraphQLWith(publisher, fmt.Sprintf(`mutation { publishArticle(id: "%s") { article { status publishedAt } } }`, id))
+	suite.Require().Nil(published["errors"])
+	article := published["data"].(map[string]interface{})["publishArticle"].(map[string]interface{})["article"].(map[string]interface{})
+	assert.Equal(suite.T(), "PUBLISHED", article["status"])
+	assert.NotNil(suite.T(), article["publishedAt"])
+
+	// Articles of other authors still need an editor
+	created = suite.executeGraphQLAs("Alice", `mutation { createArticle(input: { title: "Draft", body: "Body" }) { article { id } } }`)
+	other := created["data"].(map[string]interface{})["createArticle"].(map[string]interface{})["article"].(map[string]interface{})["id"].(string)
+	denied := suite.executeGraphQLWith(publisher, fmt.Sprintf(`mutation { publishArticle(id: "%s") { article { status } } }`, other))
+	errors := denied["errors"].([]interface{})
+	assert.Equal(suite.T(), "FORBIDDEN", errors[0].(map[string]interface{})["extensions"].(map[string]interface{})["code"])
+}
+
+// createTestArticle creates a published article by authorName.
+func (suite *IntegrationTestSuite) createTestArticle(title, body, authorName string) string {
 	mutation := fmt.Sprintf(`
         mutation {
             createArticle(input: {
                 title: "%s"
                 body: "%s"
-                authorName: "%s"
             }) {
-                id
+                article {
+                    id
+                }
             }
         }
-    `, title, body, authorName)
+    `, title, body)
+
+	response := suite.executeGraphQLAs(authorName, mutation)
+	payload := response["data"].(map[string]interface{})["createArticle"].(map[string]interface{})
+	id := payload["article"].(map[string]interface{})["id"].(string)
+	suite.publishTestArticle(id)
+	return id
+}
 
-	suite.executeGraphQL(mutation)
+// publishTestArticle publishes an article as an editor.
+func (suite *IntegrationTestSuite) publishTestArticle(id string) {
+	response := suite.executeGraphQLWith(&auth.Principal{Subject: "user-editor", Name: "editor", Roles: []auth.Role{auth.RoleEditor}},
+		fmt.Sprintf(`mutation { publishArticle(id: "%s") { article { id } } }`, id))
+	suite.Require().Nil(response["errors"])
 }
 
 func (suite *IntegrationTestSuite) executeGraphQL(query string) map[string]interface{} {
+	return suite.executeGraphQLWith(nil, query)
+}
+
+// executeGraphQLAs runs query as the author called name.
+func (suite *IntegrationTestSuite) executeGraphQLAs(name, query string) map[string]interface{} {
+	return suite.executeGraphQLWith(&auth.Principal{
+		Subject: "user-" + name,
+		Name:    name,
+		Roles:   []auth.Role{auth.RoleAuthor},
+	}, query)
+}
+
+// executeGraphQLWith runs query as principal, or anonymously when it is nil.
+func (suite *IntegrationTestSuite) executeGraphQLWith(principal *auth.Principal, query string) map[string]interface{} {
 	requestBody := map[string]string{
 		"query": query,
 	}
@@ -335,6 +813,9 @@ func (suite *IntegrationTestSuite) executeGraphQL(query string) map[string]inter
 
 	req := httptest.NewRequest("POST", "/graphql", bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	}
 
 	recorder := httptest.NewRecorder()
 	suite.handler.ServeHTTP(recorder, req)
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckIntrospection(t *testing.T) {
+	rejected := []string{
+		`{ __schema { types { name } } }`,
+		`query Type { __type(name: "Article") { fields { name } } }`,
+		`query { ...Meta } fragment Meta on Query { __schema { queryType { name } } }`,
+		`query { ... on Query { __type(name: "Author") { name } } }`,
+	}
+	for _, query := range rejected {
+		err := graph.CheckIntrospection(query)
+		if assert.NotNil(t, err, query) {
+			assert.Equal(t, apperr.CodeForbidden, err.Code)
+		}
+	}
+
+	allowed := []string{
+		`{ articles { edges { node { __typename id } } } }`,
+		`mutation { deleteArticle(id: "1") { __typename } }`,
+		`{ __schema {`,
+	}
+	for _, query := range allowed {
+		assert.Nil(t, graph.CheckIntrospection(query), query)
+	}
+}
+
+func TestDisableIntrospection_HTTP(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+	h := graph.DisableIntrospection(handler.New(&handler.Config{
+		Schema:        &schema,
+		FormatErrorFn: apperr.Format,
+	}))
+
+	post := func(query string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"query": query})
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	recorder := post(`{ __schema { types { name } } }`)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.JSONEq(t, `{"errors": [{
+		"message": "GraphQL introspection is disabled on this server",
+		"locations": [],
+		"extensions": {"code": "FORBIDDEN"}
+	}]}`, recorder.Body.String())
+
+	recorder = post(`{ apiKeys { __typename } }`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "UNAUTHENTICATED")
+}
+
+func TestDisableIntrospection_WebSocket(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+
+	wsHandler := graph.NewWebSocketHandler(&schema, http.NotFoundHandler())
+	wsHandler.DisableIntrospection = true
+	server := httptest.NewServer(wsHandler)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "connection_init"}))
+	var ack map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&ack))
+	assert.Equal(t, "connection_ack", ack["type"])
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"payload": map[string]interface{}{"query": `{ __type(name: "Article") { name } }`},
+	}))
+
+	var msg struct {
+		ID      string          `json:"id"`
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "1", msg.ID)
+	assert.Equal(t, "error", msg.Type)
+	assert.JSONEq(t, `[{
+		"message": "GraphQL introspection is disabled on this server",
+		"locations": [],
+		"extensions": {"code": "FORBIDDEN"}
+	}]`, string(msg.Payload))
+}
@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/database"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/logging"
+	"github.com/graphql-go/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logLines decodes the JSON lines written by a logger.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestLoggingMiddleware_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, config.Default().Logging)
+
+	var handlerLogged bool
+	mw := logging.Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context()).Info("inside handler")
+		handlerLogged = true
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	// An incoming ID is kept
+	req := httptest.NewRequest("GET", "/missing", nil)
+	req.Header.Set(logging.RequestIDHeader, "edge-1234")
+	recorder := httptest.NewRecorder()
+	mw.ServeHTTP(recorder, req)
+
+	require.True(t, handlerLogged)
+	assert.Equal(t, "edge-1234", recorder.Header().Get(logging.RequestIDHeader))
+
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "inside handler", lines[0]["msg"])
+	assert.Equal(t, "edge-1234", lines[0]["request_id"])
+	assert.Equal(t, "request", lines[1]["msg"])
+	assert.Equal(t, "WARN", lines[1]["level"])
+	assert.Equal(t, "edge-1234", lines[1]["request_id"])
+	assert.Equal(t, "GET", lines[1]["method"])
+	assert.Equal(t, "/missing", lines[1]["path"])
+	assert.Equal(t, float64(http.StatusNotFound), lines[1]["status"])
+	assert.Contains(t, lines[1], "duration_ms")
+
+	// IDs that could forge log lines are replaced
+	buf.Reset()
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(logging.RequestIDHeader, "abc\ndef")
+	recorder = httptest.NewRecorder()
+	mw.ServeHTTP(recorder, req)
+
+	id := recorder.Header().Get(logging.RequestIDHeader)
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, logLines(t, &buf)[1]["request_id"])
+}
+
+func TestLoggingMiddleware_GraphQLOperation(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+	schema.AddExtensions(logging.GraphQLExtension(0))
+
+	var buf bytes.Buffer
+	mw := logging.Middleware(logging.New(&buf, config.Default().Logging))(handler.New(&handler.Config{
+		Schema:        &schema,
+		FormatErrorFn: apperr.Format,
+	}))
+
+	serve := func(body string) map[string]interface{} {
+		buf.Reset()
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		mw.ServeHTTP(httptest.NewRecorder(), req)
+		lines := logLines(t, &buf)
+		return lines[len(lines)-1]
+	}
+
+	entry := serve(`{"query": "query Keys { apiKeys { id } }"}`)
+	assert.Equal(t, "query", entry["operation_type"])
+	assert.Equal(t, "Keys", entry["operation_name"])
+	assert.Equal(t, []interface{}{"UNAUTHENTICATED"}, entry["error_codes"])
+
+	entry = serve(`{"query": "mutation A { deleteArticle(id: \"1\") { deletedArticleId } } query B { apiKeys { id } }", "operationName": "B"}`)
+	assert.Equal(t, "query", entry["operation_type"])
+	assert.Equal(t, "B", entry["operation_name"])
+
+	entry = serve(`{"query": "{ apiKeys {"}`)
+	assert.Equal(t, []interface{}{"GRAPHQL_PARSE_FAILED"}, entry["error_codes"])
+	assert.NotContains(t, entry, "operation_type")
+}
+
+func TestRedact(t *testing.T) {
+	createdAfter := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	redacted := logging.Redact(map[string]interface{}{
+		"query":        "héllo world",
+		"first":        float64(10),
+		"createdAfter": createdAfter,
+		"input":        map[string]interface{}{"title": "Draft", "tags": []interface{}{"go", true}},
+		"after":        nil,
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"query":        "[REDACTED 11 chars]",
+		"first":        float64(10),
+		"createdAfter": createdAfter,
+		"input":        map[string]interface{}{"title": "[REDACTED 5 chars]", "tags": []interface{}{"[REDACTED 2 chars]", true}},
+		"after":        nil,
+	}, redacted)
+	assert.Equal(t, "[REDACTED]", logging.Redact([]byte("secret")))
+}
+
+func TestLogging_SlowOperation(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+	schema.AddExtensions(logging.GraphQLExtension(time.Nanosecond))
+
+	var buf bytes.Buffer
+	mw := logging.Middleware(logging.New(&buf, config.Default().Logging))(handler.New(&handler.Config{
+		Schema:        &schema,
+		FormatErrorFn: apperr.Format,
+	}))
+
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(
+		`{"query": "mutation Delete($id: ID!) { deleteArticle(id: $id) { deletedArticleId } }", "variables": {"id": "42"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	mw.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "slow operation", lines[0]["msg"])
+	assert.Equal(t, "WARN", lines[0]["level"])
+	assert.Equal(t, "mutation", lines[0]["operation_type"])
+	assert.Equal(t, "Delete", lines[0]["operation_name"])
+	assert.Equal(t, map[string]interface{}{"id": "[REDACTED 2 chars]"}, lines[0]["variables"])
+	assert.Equal(t, lines[1]["request_id"], lines[0]["request_id"])
+}
+
+func TestLogging_SlowQuery(t *testing.T) {
+	// Nothing listens on the port, but failed statements are timed as well
+	sqlDB, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable")
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	db := &database.DB{DB: sqlDB}
+	var buf bytes.Buffer
+	mw := logging.Middleware(logging.New(&buf, config.Default().Logging))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = db.QueryRowContext(r.Context(), `
+            SELECT COUNT(*)
+            FROM articles a
+            WHERE a.created_at > $1 AND a.title ILIKE $2`, 42, "%go%").Scan(new(int))
+	}))
+
+	mw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Len(t, logLines(t, &buf), 1, "statements are not logged by default")
+
+	buf.Reset()
+	db.SetSlowQueryThreshold(time.Nanosecond)
+	mw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "slow query", lines[0]["msg"])
+	assert.Equal(t, "SELECT COUNT(*) FROM articles a WHERE a.created_at > $1 AND a.title ILIKE $2", lines[0]["sql"])
+	assert.Equal(t, []interface{}{float64(42), "[REDACTED 4 chars]"}, lines[0]["args"])
+	assert.Equal(t, lines[1]["request_id"], lines[0]["request_id"])
+}
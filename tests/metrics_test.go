@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/database"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/metrics"
+	"github.com/gorilla/mux"
+	"github.com/graphql-go/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	return recorder.Body.String()
+}
+
+func TestMetrics_HTTPRequestsByRoute(t *testing.T) {
+	m := metrics.New()
+
+	router := mux.NewRouter()
+	router.Use(m.Middleware)
+	router.HandleFunc("/articles/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	for _, path := range []string{"/articles/1", "/articles/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `http_requests_total{code="418",method="GET",route="/articles/{id}"} 2`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/articles/{id}"} 2`)
+}
+
+func TestMetrics_GraphQLResolvers(t *testing.T) {
+	m := metrics.New()
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+	schema.AddExtensions(m.GraphQLExtension())
+
+	// Anonymous callers may not create articles or list API keys
+	result := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `mutation { createArticle(input: { title: "Title", body: "Body" }) { article { id } } }`,
+		Context:       context.Background(),
+	})
+	require.Len(t, result.Errors, 1)
+	result = graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `{ apiKeys { id } }`,
+		Context:       context.Background(),
+	})
+	require.Len(t, result.Errors, 1)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `graphql_resolver_duration_seconds_count{field="createArticle",operation="mutation"} 1`)
+	assert.Contains(t, body, `graphql_resolver_errors_total{code="UNAUTHENTICATED",field="createArticle",operation="mutation"} 1`)
+	assert.Contains(t, body, `graphql_resolver_errors_total{code="UNAUTHENTICATED",field="apiKeys",operation="query"} 1`)
+	// Only top-level fields are measured
+	assert.NotContains(t, body, `field="article"`)
+}
+
+func TestMetrics_RegisterDatabasePool(t *testing.T) {
+	m := metrics.New()
+
+	// sql.Open does not connect, which is enough for the pool statistics
+	db, err := sql.Open("postgres", "host=localhost")
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, m.Register((&database.DB{DB: db}).StatsCollector()))
+	body := scrape(t, m)
+	assert.Contains(t, body, `go_sql_open_connections{db_name="articles"} 0`)
+	assert.Contains(t, body, `go_sql_in_use_connections{db_name="articles"} 0`)
+	assert.Contains(t, body, `go_sql_wait_count_total{db_name="articles"} 0`)
+}
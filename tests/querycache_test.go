@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/cache"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/graphql-go/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewMemoryStore(2)
+
+	require.NoError(t, store.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, store.Set(ctx, "b", []byte("2"), time.Minute))
+	_, ok, _ := store.Get(ctx, "a")
+	require.True(t, ok)
+	require.NoError(t, store.Set(ctx, "c", []byte("3"), time.Minute))
+
+	_, ok, _ = store.Get(ctx, "b")
+	assert.False(t, ok, "b was the least recently used")
+	value, ok, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+	assert.Equal(t, 2, store.Len())
+
+	require.NoError(t, store.Purge(ctx))
+	assert.Equal(t, 0, store.Len())
+	_, ok, _ = store.Get(ctx, "a")
+	assert.False(t, ok)
+}
+
+func TestMemoryStore_ExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewMemoryStore(10)
+
+	require.NoError(t, store.Set(ctx, "short", []byte("1"), 10*time.Millisecond))
+	require.NoError(t, store.Set(ctx, "long", []byte("2"), time.Minute))
+	time.Sleep(20 * time.Millisecond)
+
+	_, ok, _ := store.Get(ctx, "short")
+	assert.False(t, ok)
+	_, ok, _ = store.Get(ctx, "long")
+	assert.True(t, ok)
+	assert.Equal(t, 1, store.Len(), "expired entries are dropped when looked up")
+}
+
+func TestQueryCache(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+	graphqlHandler := handler.New(&handler.Config{Schema: &schema, FormatErrorFn: apperr.Format})
+
+	executed := 0
+	queryCache := graph.NewQueryCache(cache.NewMemoryStore(10), time.Minute)
+	h := queryCache.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		executed++
+		graphqlHandler.ServeHTTP(w, r)
+	}))
+
+	post := func(query string, variables map[string]interface{}, principal *auth.Principal) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		if principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+		}
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	first := post(`query Home($show: Boolean!) { __typename @include(if: $show) }`, map[string]interface{}{"show": true}, nil)
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.JSONEq(t, `{"data":{"__typename":"Query"}}`, first.Body.String())
+	assert.Equal(t, "MISS", first.Header().Get("X-Cache"))
+	assert.Equal(t, 1, executed)
+
+	// The same query, however formatted, is answered from the cache
+	hit := post("query Home($show: Boolean!) {\n  __typename @include(if: $show)\n}", map[string]interface{}{"show": true}, nil)
+	assert.Equal(t, http.StatusOK, hit.Code)
+	assert.Equal(t, "HIT", hit.Header().Get("X-Cache"))
+	assert.Equal(t, "application/json; charset=utf-8", hit.Header().Get("Content-Type"))
+	assert.Equal(t, first.Body.String(), hit.Body.String())
+	assert.Equal(t, 1, executed)
+
+	// GET requests share the entries of POST ones
+	req := httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(`query Home($show: Boolean!) { __typename @include(if: $show) }`)+
+		"&variables="+url.QueryEscape(`{"show":true}`), nil)
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, "HIT", recorder.Header().Get("X-Cache"))
+	assert.Equal(t, 1, executed)
+
+	// Other variables and other roles are cached apart
+	assert.Equal(t, "MISS", post(`query Home($show: Boolean!) { __typename @include(if: $show) }`, map[string]interface{}{"show": false}, nil).Header().Get("X-Cache"))
+	editor := &auth.Principal{Subject: "alice", Roles: []auth.Role{auth.RoleEditor}}
+	assert.Equal(t, "MISS", post(`query Home($show: Boolean!) { __typename @include(if: $show) }`, map[string]interface{}{"show": true}, editor).Header().Get("X-Cache"))
+	assert.Equal(t, "HIT", post(`query Home($show: Boolean!) { __typename @include(if: $show) }`, map[string]interface{}{"show": true}, editor).Header().Get("X-Cache"))
+	assert.Equal(t, 3, executed)
+
+	// Authors see their own drafts, so each of them is cached apart, even under the same name
+	alice := &auth.Principal{Subject: "alice", Name: "Alice", Roles: []auth.Role{auth.RoleAuthor}}
+	bob := &auth.Principal{Subject: "bob", Name: "Alice", Roles: []auth.Role{auth.RoleAuthor}}
+	assert.Equal(t, "MISS", post(`query Home($show: Boolean!) { __typename @include(if: $show) }`, map[string]interface{}{"show": true}, alice).Header().Get("X-Cache"))
+	assert.Equal(t, "HIT", post(`query Home($show: Boolean!) { __typename @include(if: $show) }`, map[string]interface{}{"show": true}, alice).Header().Get("X-Cache"))
+	assert.Equal(t, "MISS", post(`query Home($show: Boolean!) { __typename @include(if: $show) }`, map[string]interface{}{"show": true}, bob).Header().Get("X-Cache"))
+	assert.Equal(t, 5, executed)
+
+	// Invalidation drops every entry
+	queryCache.Invalidate(context.Background())
+	assert.Equal(t, "MISS", post(`query Home($show: Boolean!) { __typename @include(if: $show) }`, map[string]interface{}{"show": true}, nil).Header().Get("X-Cache"))
+	assert.Equal(t, 6, executed)
+
+	// API keys, mutations and failed queries are never cached
+	for i := 0; i < 2; i++ {
+		failed := post(`{ apiKeys { id } }`, nil, nil)
+		assert.Contains(t, failed.Body.String(), "UNAUTHENTICATED")
+		assert.Empty(t, failed.Header().Get("X-Cache"))
+		failed = post(`query Home($show: Boolean!) { __typename @include(if: $show) }`, nil, nil)
+		assert.Contains(t, failed.Body.String(), "errors")
+		assert.Equal(t, "MISS", failed.Header().Get("X-Cache"))
+	}
+	mutation := post(`mutation { __typename }`, nil, nil)
+	assert.Empty(t, mutation.Header().Get("X-Cache"))
+	assert.Equal(t, 11, executed)
+}
+
+// failingStore is a cache.Store whose backend is unreachable.
+type failingStore struct{}
+
+func (failingStore) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, fmt.Errorf("connection refused")
+}
+
+func (failingStore) Set(context.Context, string, []byte, time.Duration) error {
+	return fmt.Errorf("connection refused")
+}
+
+func (failingStore) Purge(context.Context) error {
+	return fmt.Errorf("connection refused")
+}
+
+func TestQueryCache_StoreFailure(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+	h := graph.NewQueryCache(failingStore{}, time.Minute).Middleware(
+		handler.New(&handler.Config{Schema: &schema, FormatErrorFn: apperr.Format}))
+
+	req := httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(`{ __typename }`), nil)
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"data":{"__typename":"Query"}}`, recorder.Body.String())
+	assert.Equal(t, "MISS", recorder.Header().Get("X-Cache"))
+}
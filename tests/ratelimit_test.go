@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/ratelimit"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Rate: 0.5, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err := store.Take(context.Background(), "client", limit)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+
+	allowed, retryAfter, err := store.Take(context.Background(), "client", limit)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.InDelta(t, 2.0, retryAfter.Seconds(), 0.1)
+
+	// Other clients have their own bucket
+	allowed, _, err = store.Take(context.Background(), "other", limit)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	var body string
+	handler := ratelimit.Middleware(ratelimit.NewMemoryStore(), ratelimit.Limits{
+		Query:    ratelimit.PerMinute(2),
+		Mutation: ratelimit.PerMinute(1),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+
+	serve := func(query, remoteAddr string, principal *auth.Principal) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/graphql", bytes.NewBufferString(`{"query": "`+query+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		if principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	const query = "{ articles { totalCount } }"
+	const mutation = "mutation { deleteArticle(id: 1) { deletedArticleId } }"
+
+	// The handler still sees the request body after it was inspected
+	assert.Equal(t, http.StatusOK, serve(mutation, "10.0.0.1:1000", nil).Code)
+	assert.Contains(t, body, "deleteArticle")
+
+	limited := serve(mutation, "10.0.0.1:1001", nil)
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Contains(t, limited.Body.String(), "RATE_LIMITED")
+	retryAfter, err := strconv.Atoi(limited.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.InDelta(t, 60, retryAfter, 1)
+
+	// Queries have a budget of their own
+	assert.Equal(t, http.StatusOK, serve(query, "10.0.0.1:1002", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(query, "10.0.0.1:1003", nil).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(query, "10.0.0.1:1004", nil).Code)
+
+	// Other addresses and authenticated callers are counted separately
+	assert.Equal(t, http.StatusOK, serve(mutation, "10.0.0.2:1000", nil).Code)
+	alice := &auth.Principal{Subject: "alice", Roles: []auth.Role{auth.RoleAuthor}}
+	assert.Equal(t, http.StatusOK, serve(mutation, "10.0.0.1:1005", alice).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(mutation, "10.0.0.2:1001", alice).Code)
+
+	// Mutations sent with GET count as mutations too
+	get := httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(mutation), nil)
+	get.RemoteAddr = "10.0.0.3:1000"
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, get)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	get.RemoteAddr = "10.0.0.3:1001"
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, get)
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+}
+
+func TestProxies_ClientIP(t *testing.T) {
+	proxies, err := ratelimit.ParseProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	require.NoError(t, err)
+
+	clientIP := func(remoteAddr string, forwardedFor ...string) string {
+		req := httptest.NewRequest("GET", "/graphql", nil)
+		req.RemoteAddr = remoteAddr
+		for _, value := range forwardedFor {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		return proxies.ClientIP(req)
+	}
+
+	// Only trusted proxies may name the client
+	assert.Equal(t, "203.0.113.9", clientIP("203.0.113.9:1000", "198.51.100.1"))
+	assert.Equal(t, "198.51.100.1", clientIP("10.1.2.3:1000", "198.51.100.1"))
+	// Addresses added before the trusted proxies may be forged
+	assert.Equal(t, "198.51.100.1", clientIP("10.1.2.3:1000", "1.2.3.4, 198.51.100.1, 192.168.1.1"))
+	assert.Equal(t, "198.51.100.1", clientIP("10.1.2.3:1000", "1.2.3.4", "198.51.100.1"))
+	assert.Equal(t, "10.1.2.3", clientIP("10.1.2.3:1000"))
+
+	_, err = ratelimit.ParseProxies([]string{"proxy.internal"})
+	assert.Error(t, err)
+}
+
+func TestClients_LimitsBeforeAuthentication(t *testing.T) {
+	proxies, err := ratelimit.ParseProxies([]string{"10.0.0.1"})
+	require.NoError(t, err)
+	store := ratelimit.NewMemoryStore()
+	handler := ratelimit.Clients(store, ratelimit.PerMinute(2), proxies)(
+		ratelimit.Middleware(store, ratelimit.Limits{Query: ratelimit.PerMinute(1)})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	serve := func(forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ __typename }"), nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// Clients behind the proxy get budgets of their own
+	assert.Equal(t, http.StatusOK, serve("198.51.100.1").Code)
+	assert.Contains(t, serve("198.51.100.1").Body.String(), "too many query requests")
+	assert.Equal(t, http.StatusOK, serve("198.51.100.2").Code)
+
+	// Requests are counted per address before anything else is checked
+	limited := serve("198.51.100.1")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Contains(t, limited.Body.String(), "too many requests")
+	assert.NotEmpty(t, limited.Header().Get("Retry-After"))
+}
+
+func TestOperations_LimitsWebSocketOperations(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+	wsHandler := graph.NewWebSocketHandler(&schema, http.NotFoundHandler())
+	wsHandler.LimitOperation = ratelimit.Operations(ratelimit.NewMemoryStore(), ratelimit.Limits{
+		Query:    ratelimit.PerMinute(5),
+		Mutation: ratelimit.PerMinute(1),
+	})
+	server := httptest.NewServer(wsHandler)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "connection_init"}))
+	var ack map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&ack))
+
+	// Each mutation sent over the connection takes from the budget
+	send := func(id string) map[string]interface{} {
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"id":      id,
+			"type":    "subscribe",
+			"payload": map[string]interface{}{"query": "mutation { __typename }"},
+		}))
+		var msg map[string]interface{}
+		require.NoError(t, conn.ReadJSON(&msg))
+		if msg["type"] == "next" {
+			var complete map[string]interface{}
+			require.NoError(t, conn.ReadJSON(&complete))
+			assert.Equal(t, "complete", complete["type"])
+		}
+		return msg
+	}
+	assert.Equal(t, "next", send("1")["type"])
+	limited := send("2")
+	assert.Equal(t, "error", limited["type"])
+	payload, _ := json.Marshal(limited["payload"])
+	assert.Contains(t, string(payload), "RATE_LIMITED")
+}
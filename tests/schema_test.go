@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaSnapshot_UpToDate(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+
+	snapshot, err := os.ReadFile("../schema.graphql")
+	require.NoError(t, err)
+
+	assert.Equal(t, string(snapshot), graph.PrintSchema(schema),
+		"schema.graphql is out of date, run `go run ./cmd/schema > schema.graphql`")
+}
+
+func TestDiffSchemas_FlagsBreakingChanges(t *testing.T) {
+	oldSDL := `
+        type Query {
+            article(id: ID): Article
+            articles(first: Int): [Article!]!
+        }
+
+        type Article {
+            id: ID!
+            title: String
+            body: String!
+        }`
+
+	newSDL := `
+        type Query {
+            article(id: ID!): Article
+            articles(first: Int, query: String): [Article!]!
+        }
+
+        type Article {
+            id: ID!
+            title: String!
+        }`
+
+	changes, err := graph.DiffSchemas(oldSDL, newSDL)
+	require.NoError(t, err)
+
+	assert.Equal(t, []graph.SchemaChange{
+		{Breaking: true, Description: "argument Query.article.id changed type from ID to ID!"},
+		{Breaking: true, Description: "field Article.body was removed"},
+		{Breaking: false, Description: "argument Query.articles.query was added"},
+		{Breaking: false, Description: "field Article.title changed type from String to String!"},
+	}, changes)
+}
+
+func TestDiffSchemas_SnapshotHasNoChanges(t *testing.T) {
+	snapshot, err := os.ReadFile("../schema.graphql")
+	require.NoError(t, err)
+
+	changes, err := graph.DiffSchemas(string(snapshot), string(snapshot))
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := storage.NewDiskStore(dir, "https://cdn.example.com/images/")
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put(ctx, "cat.txt", "text/plain", strings.NewReader("meow")))
+	assert.Equal(t, "https://cdn.example.com/images/cat.txt", store.URL("cat.txt"))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temporary file is left behind")
+	assert.Equal(t, "cat.txt", entries[0].Name())
+
+	h := http.StripPrefix("/images", store.Handler())
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("GET", "/images/cat.txt", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "meow", recorder.Body.String())
+	assert.Contains(t, recorder.Header().Get("Cache-Control"), "immutable")
+	assert.Equal(t, "nosniff", recorder.Header().Get("X-Content-Type-Options"))
+
+	for _, path := range []string{"/images/missing.txt", "/images/", "/images/../cat.txt", "/images/.upload-1"} {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusNotFound, recorder.Code, path)
+	}
+
+	assert.ErrorIs(t, store.Put(ctx, "../escape.txt", "text/plain", strings.NewReader("")), storage.ErrInvalidKey)
+	require.NoError(t, store.Delete(ctx, "cat.txt"))
+	require.NoError(t, store.Delete(ctx, "cat.txt"), "deleting a missing file is not an error")
+	_, err = os.Stat(dir + "/cat.txt")
+	assert.True(t, os.IsNotExist(err))
+}
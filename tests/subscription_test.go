@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/models"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArticleBroker_PublishAndUnsubscribe(t *testing.T) {
+	broker := graph.NewArticleBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	articles := broker.Subscribe(ctx)
+	broker.Publish(&models.Article{ID: 1, Title: "Hello"})
+
+	select {
+	case article := <-articles:
+		assert.Equal(t, 1, article.ID)
+	case <-time.After(time.Second):
+		t.Fatal("expected a published article")
+	}
+
+	cancel()
+	select {
+	case _, open := <-articles:
+		assert.False(t, open)
+	case <-time.After(time.Second):
+		t.Fatal("expected the subscription channel to be closed")
+	}
+}
+
+func TestSubscription_ArticleCreatedOverWebSocket(t *testing.T) {
+	resolver := graph.NewResolver(nil, config.Default().GraphQL)
+	schema, err := graph.CreateSchema(resolver)
+	require.NoError(t, err)
+
+	notFound := http.NotFoundHandler()
+	server := httptest.NewServer(graph.NewWebSocketHandler(&schema, notFound))
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "connection_init"}))
+	var ack map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&ack))
+	assert.Equal(t, "connection_ack", ack["type"])
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"id":   "1",
+		"type": "subscribe",
+		"payload": map[string]interface{}{
+			"query": `subscription { articleCreated(author: "alice") { title author { name } } }`,
+		},
+	}))
+
+	messages := make(chan map[string]interface{})
+	go func() {
+		for {
+			var msg map[string]interface{}
+			if err := conn.ReadJSON(&msg); err != nil {
+				close(messages)
+				return
+			}
+			messages <- msg
+		}
+	}()
+
+	// The subscription is registered asynchronously, so keep publishing until
+	// the first event arrives. Bob's articles must never match the filter.
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-messages:
+			require.True(t, ok, "connection closed before receiving an event")
+			assert.Equal(t, "next", msg["type"])
+			assert.Equal(t, "1", msg["id"])
+
+			payload, _ := json.Marshal(msg["payload"])
+			assert.JSONEq(t, `{"data":{"articleCreated":{"title":"By Alice","author":{"name":"Alice"}}}}`, string(payload))
+			return
+		case <-ticker.C:
+			resolver.Broker().Publish(&models.Article{ID: 2, Title: "By Bob", Author: &models.Author{ID: 2, Name: "Bob"}})
+			resolver.Broker().Publish(&models.Article{ID: 1, Title: "By Alice", Author: &models.Author{ID: 1, Name: "Alice"}})
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/tlscert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1 with the
+// given serial number to certFile and keyFile.
+func writeCertificate(t *testing.T, certFile, keyFile string, serial int64) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+}
+
+func TestReloader_PicksUpRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertificate(t, certFile, keyFile, 1)
+
+	certs, err := tlscert.NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	// Served like main does; httptest would add a certificate of its own
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{Handler: http.NotFoundHandler(), TLSConfig: certs.TLSConfig()}
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+
+	servedSerial := func() int64 {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+	assert.Equal(t, int64(1), servedSerial())
+
+	writeCertificate(t, certFile, keyFile, 2)
+	assert.Equal(t, int64(1), servedSerial(), "the certificate only changes on reload")
+	require.NoError(t, certs.Reload())
+	assert.Equal(t, int64(2), servedSerial())
+
+	// A half-rotated pair is rejected and the current certificate kept
+	require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600))
+	assert.ErrorContains(t, certs.Reload(), "failed to load TLS certificate")
+	assert.Equal(t, int64(2), servedSerial())
+}
+
+func TestNewReloader_MissingFiles(t *testing.T) {
+	_, err := tlscert.NewReloader(filepath.Join(t.TempDir(), "tls.crt"), filepath.Join(t.TempDir(), "tls.key"))
+	assert.ErrorContains(t, err, "failed to load TLS certificate")
+}
+
+func TestLoadConfig_TLS(t *testing.T) {
+	clearConfigEnv(t)
+
+	_, err := config.Load([]string{"-server.tlsCertFile=tls.crt"})
+	assert.ErrorContains(t, err, "server.tlsCertFile and server.tlsKeyFile must be set together")
+
+	_, err = config.Load([]string{"-server.tlsCertFile=tls.crt", "-server.tlsKeyFile=tls.key", "-server.h2c"})
+	assert.ErrorContains(t, err, "server.h2c")
+
+	t.Setenv("SERVER_TLS_CERT_FILE", "tls.crt")
+	t.Setenv("SERVER_TLS_KEY_FILE", "tls.key")
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.True(t, cfg.Server.TLSEnabled())
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/tracing"
+	"github.com/graphql-go/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spansByName(spans []sdktrace.ReadOnlySpan) map[string]sdktrace.ReadOnlySpan {
+	byName := make(map[string]sdktrace.ReadOnlySpan, len(spans))
+	for _, span := range spans {
+		byName[span.Name()] = span
+	}
+	return byName
+}
+
+func TestInstrumentSchema_OperationAndResolverSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	require.NoError(t, err)
+	tracing.InstrumentSchema(&schema, provider)
+
+	result := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `query Keys { apiKeys { id } }`,
+		OperationName: "Keys",
+		Context:       context.Background(),
+	})
+	require.Len(t, result.Errors, 1)
+
+	spans := spansByName(recorder.Ended())
+	require.Len(t, spans, 2)
+	operation, resolver := spans["query Keys"], spans["Query.apiKeys"]
+	require.NotNil(t, operation)
+	require.NotNil(t, resolver)
+
+	assert.Equal(t, operation.SpanContext().SpanID(), resolver.Parent().SpanID())
+	assert.Equal(t, codes.Error, operation.Status().Code)
+	assert.Equal(t, codes.Error, resolver.Status().Code)
+	assert.Contains(t, resolver.Attributes(), attribute.String("graphql.error.code", "UNAUTHENTICATED"))
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("graphql.operation.name", "Keys"),
+		attribute.String("graphql.operation.type", "query"),
+	}, operation.Attributes())
+
+	// Documents that do not parse still end their span
+	graphql.Do(graphql.Params{Schema: schema, RequestString: `{ apiKeys {`, Context: context.Background()})
+	spans = spansByName(recorder.Ended())
+	assert.Equal(t, codes.Error, spans["GraphQL Operation"].Status().Code)
+}
+
+func TestInstrumentSchema_ResolverSpanIsParentOfNestedWork(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"hello": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						// Stands in for a SQL statement issued by the resolver
+						_, span := provider.Tracer("test").Start(p.Context, "SELECT")
+						span.End()
+						return "world", nil
+					},
+				},
+			},
+		}),
+	})
+	require.NoError(t, err)
+	tracing.InstrumentSchema(&schema, provider)
+
+	result := graphql.Do(graphql.Params{Schema: schema, RequestString: `{ hello }`, Context: context.Background()})
+	require.Empty(t, result.Errors)
+
+	spans := spansByName(recorder.Ended())
+	require.Len(t, spans, 3)
+	assert.Equal(t, spans["query"].SpanContext().SpanID(), spans["Query.hello"].Parent().SpanID())
+	assert.Equal(t, spans["Query.hello"].SpanContext().SpanID(), spans["SELECT"].Parent().SpanID())
+	assert.Equal(t, codes.Unset, spans["query"].Status().Code)
+}
+
+func TestTracingSetup_PropagatesTraceparent(t *testing.T) {
+	shutdown, err := tracing.Setup(context.Background(), config.Default().Tracing)
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+
+	spanContext := trace.SpanContextFromContext(ctx)
+	assert.True(t, spanContext.IsRemote())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanContext.TraceID().String())
+}
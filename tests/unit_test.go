@@ -1,16 +1,27 @@
 package tests
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/database"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
 	"github.com/StillLearnSVN/go-graphql-articles/internal/models"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestEncodeCursor(t *testing.T) {
 	id := 123
-	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 123456000, time.UTC)
 
 	cursor := models.EncodeCursor(id, createdAt)
 	assert.NotEmpty(t, cursor)
@@ -18,7 +29,7 @@ func TestEncodeCursor(t *testing.T) {
 	decodedID, decodedTime, err := models.DecodeCursor(cursor)
 	assert.NoError(t, err)
 	assert.Equal(t, id, decodedID)
-	assert.Equal(t, createdAt.Unix(), decodedTime.Unix())
+	assert.True(t, createdAt.Equal(decodedTime), decodedTime)
 }
 
 func TestDecodeCursor_InvalidCursor(t *testing.T) {
@@ -31,3 +42,143 @@ func TestDecodeCursor_InvalidFormat(t *testing.T) {
 	_, _, err := models.DecodeCursor("aW52YWxpZA==") // "invalid" in base64
 	assert.Error(t, err)
 }
+
+func TestFormatError_ExposesCodeAndField(t *testing.T) {
+	err := gqlerrors.NewLocatedError(apperr.BadUserInput("title cannot be empty", "input", "title"), nil)
+
+	formatted := apperr.Format(err)
+	assert.Equal(t, "title cannot be empty", formatted.Message)
+	assert.Equal(t, apperr.CodeBadUserInput, formatted.Extensions["code"])
+	assert.Equal(t, []string{"input", "title"}, formatted.Extensions["field"])
+}
+
+func TestFormatError_MasksInternalErrors(t *testing.T) {
+	for _, cause := range []error{
+		apperr.Internal(errors.New(`pq: relation "articles" does not exist`)),
+		errors.New("pq: connection refused"),
+	} {
+		formatted := apperr.Format(gqlerrors.NewLocatedError(cause, nil))
+		assert.Equal(t, "internal server error", formatted.Message)
+		assert.Equal(t, apperr.CodeInternal, formatted.Extensions["code"])
+	}
+}
+
+func TestFormatError_TimeoutAndCancellation(t *testing.T) {
+	timeout := apperr.Timeout(fmt.Errorf("failed to query articles: %w", &pq.Error{Code: "57014"}))
+	formatted := apperr.Format(gqlerrors.NewLocatedError(timeout, nil))
+	assert.Equal(t, apperr.CodeTimeout, formatted.Extensions["code"])
+	assert.NotContains(t, formatted.Message, "57014")
+
+	// Execution reports a request canceled before resolving any field as is
+	formatted = apperr.Format(context.Canceled)
+	assert.Equal(t, apperr.CodeCanceled, formatted.Extensions["code"])
+	formatted = apperr.Format(context.DeadlineExceeded)
+	assert.Equal(t, apperr.CodeTimeout, formatted.Extensions["code"])
+}
+
+func TestIsTimeout(t *testing.T) {
+	assert.True(t, database.IsTimeout(fmt.Errorf("query: %w", &pq.Error{Code: "57014"})))
+	assert.True(t, database.IsTimeout(fmt.Errorf("query: %w", context.DeadlineExceeded)))
+	assert.False(t, database.IsTimeout(&pq.Error{Code: "23505"}))
+	assert.False(t, database.IsTimeout(context.Canceled))
+}
+
+func TestCreateArticle_ReportsAllInvalidFields(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	assert.NoError(t, err)
+
+	result := graphql.Do(graphql.Params{
+		Schema: schema,
+		RequestString: `mutation {
+            createArticle(input: { title: " ", body: "" }) {
+                article { id }
+                userErrors { field code }
+            }
+        }`,
+		Context: auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice", Name: "Alice", Roles: []auth.Role{auth.RoleAuthor}}),
+	})
+	assert.Empty(t, result.Errors)
+
+	payload := result.Data.(map[string]interface{})["createArticle"].(map[string]interface{})
+	assert.Nil(t, payload["article"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"field": []interface{}{"input", "title"}, "code": "BAD_USER_INPUT"},
+		map[string]interface{}{"field": []interface{}{"input", "body"}, "code": "BAD_USER_INPUT"},
+	}, payload["userErrors"])
+}
+
+func TestArticleStatus_OnlyEditorsPublish(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	assert.NoError(t, err)
+	author := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice", Name: "Alice", Roles: []auth.Role{auth.RoleAuthor}})
+
+	for _, mutation := range []string{
+		`mutation { publishArticle(id: "1") { article { id } } }`,
+		`mutation { unpublishArticle(id: "1") { article { id } } }`,
+	} {
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: mutation, Context: author})
+		assert.Len(t, result.Errors, 1, mutation)
+		formatted := apperr.Format(result.Errors[0].OriginalError())
+		assert.Equal(t, apperr.CodeForbidden, formatted.Extensions["code"], mutation)
+	}
+
+	// Nor can they publish by updating the status
+	result := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `mutation { updateArticle(id: "1", input: { status: PUBLISHED }) { userErrors { field message } } }`,
+		Context:       author,
+	})
+	assert.Empty(t, result.Errors)
+	payload := result.Data.(map[string]interface{})["updateArticle"].(map[string]interface{})
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"field": []interface{}{"input", "status"}, "message": "articles are published with publishArticle"},
+	}, payload["userErrors"])
+}
+
+func TestDateTime_SerializesInUTC(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	createdAt := time.Date(2024, 1, 1, 19, 0, 0, 500, jakarta)
+
+	assert.Equal(t, "2024-01-01T12:00:00.0000005Z", graph.DateTime.Serialize(createdAt))
+
+	parsed := graph.DateTime.ParseValue("2024-01-01T19:00:00.0000005+07:00")
+	assert.Equal(t, createdAt.UTC(), parsed)
+	assert.Nil(t, graph.DateTime.ParseValue("01/01/2024"))
+}
+
+func TestCreateArticle_RequiresAuthentication(t *testing.T) {
+	schema, err := graph.CreateSchema(graph.NewResolver(nil, config.Default().GraphQL))
+	assert.NoError(t, err)
+
+	result := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `mutation { createArticle(input: { title: "Title", body: "Body" }) { article { id } } }`,
+		Context:       context.Background(),
+	})
+
+	assert.Len(t, result.Errors, 1)
+	formatted := apperr.Format(result.Errors[0].OriginalError())
+	assert.Equal(t, apperr.CodeUnauthenticated, formatted.Extensions["code"])
+
+	// Readers are authenticated but may not write
+	reader := &auth.Principal{Subject: "carol", Name: "Carol", Roles: []auth.Role{auth.RoleReader}}
+	result = graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `mutation { createArticle(input: { title: "Title", body: "Body" }) { article { id } } }`,
+		Context:       auth.WithPrincipal(context.Background(), reader),
+	})
+
+	assert.Len(t, result.Errors, 1)
+	formatted = apperr.Format(result.Errors[0].OriginalError())
+	assert.Equal(t, apperr.CodeForbidden, formatted.Extensions["code"])
+}
+
+func TestPrincipal_HasRole(t *testing.T) {
+	editor := &auth.Principal{Roles: []auth.Role{auth.RoleEditor}}
+	assert.True(t, editor.HasRole(auth.RoleReader))
+	assert.True(t, editor.HasRole(auth.RoleAuthor))
+	assert.True(t, editor.HasRole(auth.RoleEditor))
+	assert.False(t, editor.HasRole(auth.RoleAdmin))
+
+	assert.False(t, (&auth.Principal{}).HasRole(auth.RoleReader))
+}
@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/StillLearnSVN/go-graphql-articles/internal/apperr"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/auth"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/config"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/graph"
+	"github.com/StillLearnSVN/go-graphql-articles/internal/storage"
+	"github.com/graphql-go/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// multipartRequest builds a GraphQL multipart request carrying files under
+// the field names of fileMap, sent by an author.
+func multipartRequest(t *testing.T, operations, fileMap string, files map[string][]byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("operations", operations))
+	require.NoError(t, writer.WriteField("map", fileMap))
+	for name, content := range files {
+		part, err := writer.CreateFormFile(name, name+".bin")
+		require.NoError(t, err)
+		part.Write(content)
+	}
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/graphql", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{
+		Subject: "alice", Name: "Alice", Roles: []auth.Role{auth.RoleAuthor},
+	}))
+}
+
+// pngImage returns a tiny PNG image.
+func pngImage(t *testing.T) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))))
+	return buf.Bytes()
+}
+
+func TestMultipartRequests_RewritesOperations(t *testing.T) {
+	var received []byte
+	h := graph.MultipartRequests(1<<20, 1024, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		received, _ = io.ReadAll(r.Body)
+	}))
+
+	req := multipartRequest(t,
+		`[{"query": "mutation ($file: Upload!) { a }", "variables": {"file": null, "n": 12345678901}},
+		  {"query": "mutation ($files: [Upload!]!) { b }", "variables": {"files": [null, null]}}]`,
+		`{"one": ["0.variables.file", "1.variables.files.0"], "two": ["1.variables.files.1"]}`,
+		map[string][]byte{"one": []byte("1"), "two": []byte("2")})
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `[
+		{"query": "mutation ($file: Upload!) { a }", "variables": {"file": "one", "n": 12345678901}},
+		{"query": "mutation ($files: [Upload!]!) { b }", "variables": {"files": ["one", "two"]}}
+	]`, string(received))
+}
+
+func TestMultipartRequests_Rejected(t *testing.T) {
+	h := graph.MultipartRequests(2048, 600, nil)(http.NotFoundHandler())
+	operations := `{"query": "mutation ($file: Upload!) { a }", "variables": {"file": null}}`
+
+	cases := []struct {
+		name    string
+		req     *http.Request
+		status  int
+		message string
+	}{
+		{"missing file", multipartRequest(t, operations, `{"0": ["variables.file"]}`, nil),
+			http.StatusBadRequest, `file \"0\" is missing`},
+		{"unknown path", multipartRequest(t, operations, `{"0": ["variables.other"]}`, map[string][]byte{"0": []byte("x")}),
+			http.StatusBadRequest, `map path \"variables.other\" does not exist in operations`},
+		{"invalid operations", multipartRequest(t, `{`, `{}`, nil),
+			http.StatusBadRequest, "operations must be a JSON request or batch"},
+		{"file too large", multipartRequest(t, operations, `{"0": ["variables.file"]}`, map[string][]byte{"0": bytes.Repeat([]byte("x"), 601)}),
+			http.StatusRequestEntityTooLarge, "file is too large"},
+		{"request too large", multipartRequest(t, operations, `{"0": ["variables.file"], "1": ["variables.file"], "2": ["variables.file"]}`,
+			map[string][]byte{"0": bytes.Repeat([]byte("x"), 600), "1": bytes.Repeat([]byte("x"), 600), "2": bytes.Repeat([]byte("x"), 600)}),
+			http.StatusRequestEntityTooLarge, "request body is too large"},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, c.req)
+		assert.Equal(t, c.status, recorder.Code, c.name)
+		assert.Contains(t, recorder.Body.String(), c.message, c.name)
+		assert.Contains(t, recorder.Body.String(), "BAD_USER_INPUT", c.name)
+	}
+}
+
+func TestMultipartRequests_ChecksCallerFirst(t *testing.T) {
+	limited := 0
+	h := graph.MultipartRequests(1<<20, 1024, func(r *http.Request, operationType string) error {
+		assert.Equal(t, "mutation", operationType)
+		limited++
+		if limited > 1 {
+			return apperr.RateLimited("too many mutation requests, retry in 60 seconds")
+		}
+		return nil
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(principal *auth.Principal) *httptest.ResponseRecorder {
+		req := multipartRequest(t, `{"query": "mutation ($file: Upload!) { a }", "variables": {"file": null}}`,
+			`{"0": ["variables.file"]}`, map[string][]byte{"0": []byte("x")})
+		ctx := context.Background()
+		if principal != nil {
+			ctx = auth.WithPrincipal(ctx, principal)
+		}
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, req.WithContext(ctx))
+		return recorder
+	}
+
+	// Callers who may not upload are turned away without being charged
+	anonymous := serve(nil)
+	assert.Equal(t, http.StatusUnauthorized, anonymous.Code)
+	assert.Contains(t, anonymous.Body.String(), "UNAUTHENTICATED")
+	reader := serve(&auth.Principal{Subject: "bob", Roles: []auth.Role{auth.RoleReader}})
+	assert.Equal(t, http.StatusForbidden, reader.Code)
+	assert.Equal(t, 0, limited)
+
+	author := &auth.Principal{Subject: "alice", Roles: []auth.Role{auth.RoleAuthor}}
+	assert.Equal(t, http.StatusOK, serve(author).Code)
+	throttled := serve(author)
+	assert.Equal(t, http.StatusTooManyRequests, throttled.Code)
+	assert.Contains(t, throttled.Body.String(), "RATE_LIMITED")
+}
+
+func TestUploadImage_Validation(t *testing.T) {
+	resolver := graph.NewResolver(nil, config.Default().GraphQL)
+	images, err := storage.NewDiskStore(t.TempDir(), "/images")
+	require.NoError(t, err)
+	resolver.SetImageStore(images)
+	schema, err := graph.CreateSchema(resolver)
+	require.NoError(t, err)
+	h := graph.MultipartRequests(10<<20, 1<<20, nil)(handler.New(&handler.Config{Schema: &schema, FormatErrorFn: apperr.Format}))
+
+	upload := func(req *http.Request) map[string]interface{} {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{
+			Subject: "alice", Name: "Alice", Roles: []auth.Role{auth.RoleAuthor},
+		}))
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		var response struct {
+			Data struct {
+				UploadImage map[string]interface{} `json:"uploadImage"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		return response.Data.UploadImage
+	}
+	const mutation = `mutation ($file: Upload!) { uploadImage(articleId: "1", file: $file) { image { id } userErrors { field message } } }`
+
+	operations, _ := json.Marshal(map[string]interface{}{"query": mutation, "variables": map[string]interface{}{"file": nil}})
+	payload := upload(multipartRequest(t, string(operations), `{"0": ["variables.file"]}`,
+		map[string][]byte{"0": []byte("not an image")}))
+	assert.Nil(t, payload["image"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"field":   []interface{}{"file"},
+		"message": "the file must be a PNG, JPEG, GIF or WebP image",
+	}}, payload["userErrors"])
+
+	// Upload variables of plain JSON requests refer to no file
+	body, _ := json.Marshal(map[string]interface{}{"query": mutation, "variables": map[string]interface{}{"file": "0"}})
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	payload = upload(req)
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"field":   []interface{}{"file"},
+		"message": "the file must be sent in a multipart request",
+	}}, payload["userErrors"])
+}